@@ -20,9 +20,23 @@ func main() {
 		panic(errors.New("port not set"))
 	}
 
+	adminPort := os.Getenv("ADMIN_PORT")
+	if adminPort != "" {
+		adminMux := http.NewServeMux()
+		adminMux.Handle("/health", app.HealthHandler)
+
+		go func() {
+			log.Printf("starting proxy admin on port %s", adminPort)
+			err := http.ListenAndServe(adminPort, adminMux)
+			if err != nil {
+				log.Printf("proxy admin stopped %s", err)
+			}
+		}()
+	}
+
 	log.Printf("starting proxy on port %s", port)
 
-	err = http.ListenAndServe(port, app)
+	err = http.ListenAndServe(port, app.Handler)
 
 	if err != nil {
 		if errors.Is(http.ErrServerClosed, err) {
@@ -3,13 +3,16 @@ module github.com/vjerci/reverse-proxy
 go 1.20
 
 require (
+	github.com/andybalholm/brotli v1.1.1
 	github.com/bradleyjkemp/cupaloy/v2 v2.8.0
 	github.com/mitchellh/mapstructure v1.5.0
+	github.com/stretchr/testify v1.8.4
+	golang.org/x/net v0.17.0
+	golang.org/x/text v0.13.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.8.4 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
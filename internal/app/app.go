@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -13,32 +14,221 @@ import (
 	customlog "github.com/vjerci/reverse-proxy/internal/log"
 	"github.com/vjerci/reverse-proxy/internal/mask"
 	"github.com/vjerci/reverse-proxy/internal/proxy"
+	"github.com/vjerci/reverse-proxy/internal/route"
 	"github.com/vjerci/reverse-proxy/internal/server"
 )
 
 var ErrGuardCreation = errors.New("failed to instantiate blocking guards")
+var ErrRouterCreation = errors.New("failed to instantiate router")
+var ErrMaskingPolicyCreation = errors.New("failed to instantiate masking policy")
+var ErrInspectorCreation = errors.New("failed to instantiate masking inspectors")
+var ErrUpstreamWithoutTargets = errors.New("upstream needs host or targets")
 
-func Build() (http.HandlerFunc, error) {
+const defaultUpstream = "default"
+const defaultTimeout = 2 * time.Second
+
+type App struct {
+	Handler http.HandlerFunc
+	// HealthHandler exposes health state of upstream targets
+	HealthHandler http.Handler
+}
+
+func Build() (*App, error) {
 	configData, err := config.Load(os.Getenv("CONFIG_FILE"))
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("proxy forwarding to %s://%s", configData.ForwardScheme, configData.ForwardHost)
+	router, upstreams, err := buildRouter(configData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRouterCreation, err)
+	}
 
 	guard, err := block.GuardsFromInterface(configData.Block, &block.InterfaceGuardDecoder{})
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrGuardCreation, err)
 	}
 
-	inspector := mask.NewJSONInspector(mask.NewJSONMask(), mask.NewPIIClassifier(mask.NewDefaultPIIPatterns()))
-	proxy := proxy.NewProxy(&http.Client{
-		Timeout: time.Duration(2 * time.Second),
-	})
+	masking, err := buildMaskingPolicy(configData.Masking, log.Default())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMaskingPolicyCreation, err)
+	}
+
+	inspectors, err := buildInspectors(configData.Masking)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInspectorCreation, err)
+	}
+
+	timeout := time.Duration(configData.Timeout)
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	// timeout covers only waiting for response headers so streamed bodies aren't cut off
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
+
+	client := &http.Client{
+		Transport: transport,
+	}
+	// circuit breaker wraps retries so open circuit fails fast without retrying
+	proxyInstance := proxy.NewCircuitBreakerProxy(proxy.NewRetryProxy(proxy.NewProxy(client), log.Default()))
+
+	healthChecker := proxy.NewHealthChecker(client, upstreams)
+	healthChecker.Start(context.Background())
 
 	responseWriterFactory := &customlog.ResponseWriterFactoryInstance{
-		Logger: log.Default(),
+		Logger:             log.Default(),
+		LogRequestBody:     logRequestBody(configData.Buffering),
+		MaxStreamedBodyLog: configData.Buffering.MaxStreamedBodyLog,
+	}
+
+	limits := server.BufferLimits{
+		MaxRequestBody:  configData.Buffering.MaxRequestBody,
+		MaxResponseBody: configData.Buffering.MaxResponseBody,
+	}
+
+	return &App{
+		Handler:       http.HandlerFunc(server.Handle(inspectors, responseWriterFactory, guard, router, proxyInstance, limits, masking)),
+		HealthHandler: healthChecker,
+	}, nil
+}
+
+// request bodies were always logged, so they still are unless logging them is turned off explicitly
+func logRequestBody(bufferingConfig config.BufferingConfig) bool {
+	return bufferingConfig.LogRequestBody == nil || *bufferingConfig.LogRequestBody
+}
+
+// forward_host and forward_scheme are kept as a shorthand for a single catch all route
+func buildRouter(configData *config.ConfigData) (route.Router, []*proxy.Upstream, error) {
+	upstreamsConfig := configData.Upstreams
+	routesConfig := configData.Routes
+
+	if len(routesConfig) == 0 && configData.ForwardHost != "" {
+		upstreamsConfig = map[string]config.UpstreamConfig{
+			defaultUpstream: {
+				Host:   configData.ForwardHost,
+				Scheme: configData.ForwardScheme,
+			},
+		}
+		routesConfig = []config.RouteConfig{
+			{
+				Upstream: defaultUpstream,
+			},
+		}
+	}
+
+	upstreams := make(map[string]*proxy.Upstream, len(upstreamsConfig))
+	upstreamsList := make([]*proxy.Upstream, 0, len(upstreamsConfig))
+	for name, upstreamConfig := range upstreamsConfig {
+		upstream, err := buildUpstream(name, upstreamConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		upstreams[name] = upstream
+		upstreamsList = append(upstreamsList, upstream)
+	}
+
+	routes := make([]route.Route, 0, len(routesConfig))
+	for _, routeConfig := range routesConfig {
+		var maskDirection mask.Direction
+		if routeConfig.Mask != "" {
+			var err error
+
+			maskDirection, err = mask.ParseDirection(routeConfig.Mask)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		routes = append(routes, route.Route{
+			Host:          routeConfig.Host,
+			PathPrefix:    routeConfig.PathPrefix,
+			StripPrefix:   routeConfig.StripPrefix,
+			RewritePrefix: routeConfig.RewritePrefix,
+			Upstream:      routeConfig.Upstream,
+			MaskDirection: maskDirection,
+		})
+
+		log.Printf("proxy routing host '%s' path '%s' to upstream %s", routeConfig.Host, routeConfig.PathPrefix, routeConfig.Upstream)
+	}
+
+	router, err := route.NewRouter(routes, upstreams)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return router, upstreamsList, nil
+}
+
+func buildUpstream(name string, upstreamConfig config.UpstreamConfig) (*proxy.Upstream, error) {
+	targetsConfig := upstreamConfig.Targets
+	if upstreamConfig.Host != "" {
+		targetsConfig = append([]config.TargetConfig{{Host: upstreamConfig.Host}}, targetsConfig...)
+	}
+
+	// upstream without targets would fail every request routed to it
+	if len(targetsConfig) == 0 {
+		return nil, fmt.Errorf("upstream %s: %w", name, ErrUpstreamWithoutTargets)
+	}
+
+	targets := make([]*proxy.Target, 0, len(targetsConfig))
+	for _, targetConfig := range targetsConfig {
+		targets = append(targets, proxy.NewTarget(targetConfig.Host, targetConfig.Weight))
+	}
+
+	balancer, err := proxy.NewBalancer(upstreamConfig.Balancer, targets, upstreamConfig.HashHeader)
+	if err != nil {
+		return nil, fmt.Errorf("upstream %s: %w", name, err)
+	}
+
+	for _, target := range targets {
+		log.Printf("upstream %s target %s://%s weight %d", name, upstreamConfig.Scheme, target.Host, target.Weight)
+	}
+
+	upstream := &proxy.Upstream{
+		Name:     name,
+		Scheme:   upstreamConfig.Scheme,
+		Targets:  targets,
+		Balancer: balancer,
+	}
+
+	if upstreamConfig.HealthCheck != nil {
+		upstream.HealthCheck = &proxy.ActiveHealthCheck{
+			Path:           upstreamConfig.HealthCheck.Path,
+			Interval:       time.Duration(upstreamConfig.HealthCheck.Interval),
+			Timeout:        time.Duration(upstreamConfig.HealthCheck.Timeout),
+			ExpectedStatus: upstreamConfig.HealthCheck.ExpectedStatus,
+		}
+	}
+
+	if upstreamConfig.PassiveHealthCheck != nil {
+		upstream.PassiveHealthCheck = &proxy.PassiveHealthCheck{
+			MaxFailures: upstreamConfig.PassiveHealthCheck.MaxFailures,
+			Cooldown:    time.Duration(upstreamConfig.PassiveHealthCheck.Cooldown),
+		}
+	}
+
+	if upstreamConfig.Retry != nil {
+		upstream.RetryPolicy = &proxy.RetryPolicy{
+			MaxAttempts:        upstreamConfig.Retry.MaxAttempts,
+			InitialBackoff:     time.Duration(upstreamConfig.Retry.InitialBackoff),
+			MaxBackoff:         time.Duration(upstreamConfig.Retry.MaxBackoff),
+			RetryOnStatus:      upstreamConfig.Retry.RetryOnStatus,
+			RetryNonIdempotent: upstreamConfig.Retry.RetryNonIdempotent,
+		}
+	}
+
+	if upstreamConfig.CircuitBreaker != nil {
+		upstream.CircuitBreaker = &proxy.CircuitBreakerPolicy{
+			FailureRatio:     upstreamConfig.CircuitBreaker.FailureRatio,
+			MinRequests:      upstreamConfig.CircuitBreaker.MinRequests,
+			Window:           time.Duration(upstreamConfig.CircuitBreaker.Window),
+			OpenDuration:     time.Duration(upstreamConfig.CircuitBreaker.OpenDuration),
+			HalfOpenRequests: upstreamConfig.CircuitBreaker.HalfOpenRequests,
+		}
 	}
 
-	return http.HandlerFunc(server.Handle(inspector, responseWriterFactory, guard, proxy, configData.ForwardHost, configData.ForwardScheme)), nil
+	return upstream, nil
 }
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vjerci/reverse-proxy/internal/config"
+)
+
+func TestBuildUpstream(t *testing.T) {
+	testCases := []struct {
+		testName        string
+		upstreamConfig  config.UpstreamConfig
+		expectedTargets []string
+		expectedError   error
+	}{
+		{
+			testName:        "host",
+			upstreamConfig:  config.UpstreamConfig{Host: "users:8000", Scheme: "http"},
+			expectedTargets: []string{"users:8000"},
+		},
+		{
+			testName: "host_and_targets",
+			upstreamConfig: config.UpstreamConfig{
+				Host:    "users-1:8000",
+				Scheme:  "http",
+				Targets: []config.TargetConfig{{Host: "users-2:8000", Weight: 2}},
+			},
+			expectedTargets: []string{"users-1:8000", "users-2:8000"},
+		},
+		{
+			testName:       "no_targets",
+			upstreamConfig: config.UpstreamConfig{Scheme: "http"},
+			expectedError:  ErrUpstreamWithoutTargets,
+		},
+	}
+
+	for _, test := range testCases {
+		upstream, err := buildUpstream("users", test.upstreamConfig)
+		if !errors.Is(err, test.expectedError) {
+			t.Fatalf("for test %s expected error %v got %v instead", test.testName, test.expectedError, err)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if len(upstream.Targets) != len(test.expectedTargets) {
+			t.Fatalf("for test %s expected %d targets got %d instead", test.testName, len(test.expectedTargets), len(upstream.Targets))
+		}
+
+		for i, target := range upstream.Targets {
+			if target.Host != test.expectedTargets[i] {
+				t.Fatalf("for test %s expected target %s got %s instead", test.testName, test.expectedTargets[i], target.Host)
+			}
+		}
+	}
+}
+
+func TestLogRequestBody(t *testing.T) {
+	enabled := true
+	disabled := false
+
+	testCases := []struct {
+		testName        string
+		bufferingConfig config.BufferingConfig
+		expected        bool
+	}{
+		{
+			testName:        "unset",
+			bufferingConfig: config.BufferingConfig{},
+			expected:        true,
+		},
+		{
+			testName:        "enabled",
+			bufferingConfig: config.BufferingConfig{LogRequestBody: &enabled},
+			expected:        true,
+		},
+		{
+			testName:        "disabled",
+			bufferingConfig: config.BufferingConfig{LogRequestBody: &disabled},
+			expected:        false,
+		},
+	}
+
+	for _, test := range testCases {
+		if logRequestBody(test.bufferingConfig) != test.expected {
+			t.Fatalf("for test %s expected %t", test.testName, test.expected)
+		}
+	}
+}
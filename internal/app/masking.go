@@ -0,0 +1,382 @@
+package app
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/vjerci/reverse-proxy/internal/config"
+	customlog "github.com/vjerci/reverse-proxy/internal/log"
+	"github.com/vjerci/reverse-proxy/internal/mask"
+	"github.com/vjerci/reverse-proxy/internal/server"
+)
+
+var ErrUnknownMaskStrategy = errors.New("unknown mask strategy")
+var ErrEmptyMaskingRule = errors.New("masking rule needs field, values or paths")
+var ErrMaskChar = errors.New("mask char must be a single character")
+var ErrMaskingKey = errors.New("couldn't load masking key")
+var ErrUnknownContentSyntax = errors.New("unknown content syntax")
+
+const maskStrategyReplace = "replace"
+const maskStrategyKeepLast = "keep_last"
+const maskStrategyEmail = "email"
+const maskStrategySubstitute = "substitute"
+const maskStrategyFixedWidth = "fixed_width"
+const maskStrategyFormatPreserving = "format_preserving"
+const maskStrategyHMAC = "hmac"
+const maskStrategyEncrypt = "encrypt"
+const maskStrategyDrop = "drop"
+const maskStrategyNull = "null"
+const maskStrategyEmpty = "empty"
+const maskStrategyRedact = "redact"
+
+const contentSyntaxJSON = "json"
+const contentSyntaxXML = "xml"
+const contentSyntaxForm = "form"
+const contentSyntaxMultipart = "multipart"
+const contentSyntaxNDJSON = "ndjson"
+const contentSyntaxEventStream = "event_stream"
+
+const defaultMaskChar = '*'
+const defaultMaskKeep = 4
+const defaultMaskWidth = 8
+const defaultMaskMarker = "[REDACTED]"
+
+// empty masking config keeps default policy of masking only GET responses and passing bodies without inspector
+func buildMaskingPolicy(maskingConfig config.MaskingConfig, logger customlog.Logger) (server.MaskingPolicy, error) {
+	policy := server.DefaultMaskingPolicy()
+	policy.Logger = logger
+
+	if maskingConfig.Unknown != "" {
+		unknown, err := mask.ParseUnknownContentPolicy(maskingConfig.Unknown)
+		if err != nil {
+			return policy, err
+		}
+
+		policy.Unknown = unknown
+	}
+
+	if maskingConfig.Direction != "" {
+		direction, err := mask.ParseDirection(maskingConfig.Direction)
+		if err != nil {
+			return policy, err
+		}
+
+		policy.Default = direction
+	}
+
+	if maskingConfig.Methods != nil {
+		policy.Methods = make(map[string]mask.Direction, len(maskingConfig.Methods))
+	}
+
+	for method, directionConfig := range maskingConfig.Methods {
+		direction, err := mask.ParseDirection(directionConfig)
+		if err != nil {
+			return policy, err
+		}
+
+		policy.Methods[strings.ToUpper(method)] = direction
+	}
+
+	return policy, nil
+}
+
+// inspectors are registered under content type of body they mask, all of them share the same rules.
+// types with +json and +xml suffix, like application/soap+xml, are masked by json and xml inspectors without being registered
+func buildInspectors(maskingConfig config.MaskingConfig) (*mask.Registry, error) {
+	rules, err := buildMaskingRules(maskingConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonInspector := mask.NewJSONRulesInspector(rules)
+	if maskingConfig.ExactNumbers {
+		jsonInspector = mask.NewExactJSONRulesInspector(rules)
+	}
+
+	syntaxes := map[string]mask.Inspector{
+		contentSyntaxJSON:        jsonInspector,
+		contentSyntaxXML:         mask.NewXMLRulesInspector(rules),
+		contentSyntaxForm:        mask.NewFormRulesInspector(rules),
+		contentSyntaxMultipart:   mask.NewMultipartRulesInspector(rules, maskingConfig.DropFiles),
+		contentSyntaxNDJSON:      mask.NewNDJSONInspector(jsonInspector),
+		contentSyntaxEventStream: mask.NewEventStreamInspector(jsonInspector),
+	}
+
+	contentTypes := map[string]string{
+		"application/json":                  contentSyntaxJSON,
+		"application/xml":                   contentSyntaxXML,
+		"text/xml":                          contentSyntaxXML,
+		"application/x-www-form-urlencoded": contentSyntaxForm,
+		"multipart/form-data":               contentSyntaxMultipart,
+		"application/x-ndjson":              contentSyntaxNDJSON,
+		"text/event-stream":                 contentSyntaxEventStream,
+	}
+
+	for contentType, syntax := range maskingConfig.ContentTypes {
+		contentTypes[contentType] = syntax
+	}
+
+	registry := mask.NewRegistry()
+
+	for contentType, syntax := range contentTypes {
+		inspector, ok := syntaxes[syntax]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s for content type %s", ErrUnknownContentSyntax, syntax, contentType)
+		}
+
+		err := registry.Register(contentType, inspector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return registry, nil
+}
+
+// without configured rules fields matching default PII patterns by name are masked with default masks, value patterns are opt in
+// as they also match values which only look like PII.
+// fields on excluded paths are skipped by every rule
+func buildMaskingRules(maskingConfig config.MaskingConfig) ([]mask.Rule, error) {
+	exclude, err := mask.ParsePathPatterns(maskingConfig.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(maskingConfig.Rules) == 0 {
+		return []mask.Rule{
+			{
+				Classifier: mask.NewPIIClassifier(mask.NewDefaultPIIPatterns()),
+				Exclude:    exclude,
+				Mask:       mask.NewJSONMask(),
+			},
+		}, nil
+	}
+
+	keys, err := loadMaskingKeys(maskingConfig.Keys)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]mask.Rule, 0, len(maskingConfig.Rules))
+	for i, ruleConfig := range maskingConfig.Rules {
+		ruleConfig.Exclude = append(ruleConfig.Exclude, maskingConfig.Exclude...)
+
+		// rules can have no field, so they are identified by their index in the config
+		rule, err := buildMaskingRule(ruleConfig, keys)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func buildMaskingRule(ruleConfig config.MaskingRuleConfig, keys map[string][]byte) (mask.Rule, error) {
+	if ruleConfig.Field == "" && len(ruleConfig.Values) == 0 && len(ruleConfig.Paths) == 0 {
+		return mask.Rule{}, ErrEmptyMaskingRule
+	}
+
+	var rule mask.Rule
+
+	if ruleConfig.Field != "" {
+		fieldRegexp, err := regexp.Compile(ruleConfig.Field)
+		if err != nil {
+			return mask.Rule{}, err
+		}
+
+		rule.Classifier = mask.NewPIIClassifier([]mask.PIIPattern{
+			&mask.PIIClassifierPattern{
+				Regexp: fieldRegexp,
+			},
+		})
+	}
+
+	if len(ruleConfig.Values) > 0 {
+		patterns, err := mask.PIIValuePatterns(ruleConfig.Values)
+		if err != nil {
+			return mask.Rule{}, err
+		}
+
+		rule.Values = mask.NewPIIValueClassifier(patterns)
+	}
+
+	if len(ruleConfig.Paths) > 0 {
+		paths, err := mask.ParsePathPatterns(ruleConfig.Paths)
+		if err != nil {
+			return mask.Rule{}, err
+		}
+
+		rule.Paths = paths
+	}
+
+	if len(ruleConfig.Exclude) > 0 {
+		exclude, err := mask.ParsePathPatterns(ruleConfig.Exclude)
+		if err != nil {
+			return mask.Rule{}, err
+		}
+
+		rule.Exclude = exclude
+	}
+
+	fieldTypes := make([]mask.FieldType, 0, len(ruleConfig.Types))
+	for _, typeConfig := range ruleConfig.Types {
+		fieldType, err := mask.ParseFieldType(typeConfig)
+		if err != nil {
+			return mask.Rule{}, err
+		}
+
+		fieldTypes = append(fieldTypes, fieldType)
+	}
+
+	fieldMask, err := buildMask(ruleConfig.Mask, keys)
+	if err != nil {
+		return mask.Rule{}, err
+	}
+
+	rule.Types = fieldTypes
+	rule.Mask = fieldMask
+
+	return rule, nil
+}
+
+func buildMask(maskConfig config.MaskConfig, keys map[string][]byte) (mask.Mask, error) {
+	switch maskConfig.Strategy {
+	case "", maskStrategyReplace:
+		jsonMask := mask.NewJSONMask()
+
+		if maskConfig.String != nil {
+			jsonMask.String = &mask.FixedStringMask{Value: *maskConfig.String}
+		}
+
+		if maskConfig.Float64 != nil {
+			jsonMask.Float64 = &mask.FixedFloat64Mask{Value: *maskConfig.Float64}
+		}
+
+		if maskConfig.Bool != nil {
+			jsonMask.Boolean = &mask.FixedBooleanMask{Value: *maskConfig.Bool}
+		}
+
+		return jsonMask, nil
+	case maskStrategyFormatPreserving:
+		return &mask.JSONMask{
+			String:  &mask.FormatPreservingMask{},
+			Float64: &mask.FormatPreservingFloat64Mask{},
+			Number:  &mask.FormatPreservingNumberMask{},
+			Boolean: &mask.BooleanMask{},
+		}, nil
+	case maskStrategyDrop:
+		return &mask.DropMask{}, nil
+	case maskStrategyNull:
+		return &mask.NullMask{}, nil
+	case maskStrategyEmpty:
+		return &mask.EmptyMask{}, nil
+	case maskStrategyRedact:
+		marker := maskConfig.Marker
+		if marker == "" {
+			marker = defaultMaskMarker
+		}
+
+		return &mask.MarkerMask{Marker: marker}, nil
+	case maskStrategyHMAC:
+		secret, ok := keys[maskConfig.KeyID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", mask.ErrUnknownKeyID, maskConfig.KeyID)
+		}
+
+		tokenizer, err := mask.NewHMACTokenizer(maskConfig.KeyID, secret, maskConfig.Prefix, maskConfig.Length)
+		if err != nil {
+			return nil, err
+		}
+
+		return &mask.TokenMask{Tokenizer: tokenizer}, nil
+	case maskStrategyEncrypt:
+		tokenizer, err := mask.NewAEADTokenizer(keys, maskConfig.KeyID, maskConfig.Prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		return &mask.TokenMask{Tokenizer: tokenizer}, nil
+	}
+
+	char, err := maskChar(maskConfig.Char)
+	if err != nil {
+		return nil, err
+	}
+
+	// partial strategies mask only strings, numbers and booleans get default masks
+	jsonMask := mask.NewJSONMask()
+
+	switch maskConfig.Strategy {
+	case maskStrategyKeepLast:
+		keep := maskConfig.Keep
+		if keep <= 0 {
+			keep = defaultMaskKeep
+		}
+
+		jsonMask.String = &mask.KeepLastMask{Keep: keep, Char: char}
+	case maskStrategyEmail:
+		jsonMask.String = &mask.EmailMask{Char: char}
+	case maskStrategySubstitute:
+		jsonMask.String = &mask.SubstituteMask{Char: char}
+	case maskStrategyFixedWidth:
+		width := maskConfig.Width
+		if width <= 0 {
+			width = defaultMaskWidth
+		}
+
+		jsonMask.String = &mask.FixedWidthMask{Width: width, Char: char}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMaskStrategy, maskConfig.Strategy)
+	}
+
+	return jsonMask, nil
+}
+
+func maskChar(char string) (rune, error) {
+	if char == "" {
+		return defaultMaskChar, nil
+	}
+
+	runes := []rune(char)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("%w: %s", ErrMaskChar, char)
+	}
+
+	return runes[0], nil
+}
+
+func loadMaskingKeys(keysConfig map[string]config.MaskingKeyConfig) (map[string][]byte, error) {
+	keys := make(map[string][]byte, len(keysConfig))
+
+	for keyID, keyConfig := range keysConfig {
+		encoded := os.Getenv(keyConfig.Env)
+
+		if keyConfig.File != "" {
+			content, err := os.ReadFile(keyConfig.File)
+			if err != nil {
+				return nil, fmt.Errorf("%w %s: %w", ErrMaskingKey, keyID, err)
+			}
+
+			encoded = string(content)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %w", ErrMaskingKey, keyID, err)
+		}
+
+		if len(key) == 0 {
+			return nil, fmt.Errorf("%w %s: key is empty", ErrMaskingKey, keyID)
+		}
+
+		keys[keyID] = key
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,397 @@
+package app
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vjerci/reverse-proxy/internal/config"
+	"github.com/vjerci/reverse-proxy/internal/mask"
+)
+
+func TestBuildMask(t *testing.T) {
+	replacement := "redacted"
+
+	keys := map[string][]byte{
+		"k1": []byte("0123456789abcdef0123456789abcdef"),
+	}
+
+	testCases := []struct {
+		testName      string
+		maskConfig    config.MaskConfig
+		expectedValue interface{}
+	}{
+		{
+			testName:      "default_replace",
+			maskConfig:    config.MaskConfig{},
+			expectedValue: "x",
+		},
+		{
+			testName:      "replace_with_string",
+			maskConfig:    config.MaskConfig{Strategy: "replace", String: &replacement},
+			expectedValue: "redacted",
+		},
+		{
+			testName:      "keep_last",
+			maskConfig:    config.MaskConfig{Strategy: "keep_last", Keep: 2},
+			expectedValue: "*************om",
+		},
+		{
+			testName:      "keep_last_default_keep_and_char",
+			maskConfig:    config.MaskConfig{Strategy: "keep_last", Char: "#"},
+			expectedValue: "###########.com",
+		},
+		{
+			testName:      "email",
+			maskConfig:    config.MaskConfig{Strategy: "email"},
+			expectedValue: "j***@domain.com",
+		},
+		{
+			testName:      "substitute",
+			maskConfig:    config.MaskConfig{Strategy: "substitute"},
+			expectedValue: "***************",
+		},
+		{
+			testName:      "fixed_width",
+			maskConfig:    config.MaskConfig{Strategy: "fixed_width", Width: 3},
+			expectedValue: "***",
+		},
+		{
+			testName:      "format_preserving",
+			maskConfig:    config.MaskConfig{Strategy: "format_preserving"},
+			expectedValue: "xxxx@xxxxxx.xxx",
+		},
+		{
+			testName:      "empty",
+			maskConfig:    config.MaskConfig{Strategy: "empty"},
+			expectedValue: "",
+		},
+		{
+			testName:      "redact_default_marker",
+			maskConfig:    config.MaskConfig{Strategy: "redact"},
+			expectedValue: "[REDACTED]",
+		},
+		{
+			testName:      "redact",
+			maskConfig:    config.MaskConfig{Strategy: "redact", Marker: "[X]"},
+			expectedValue: "[X]",
+		},
+		{
+			testName:      "null",
+			maskConfig:    config.MaskConfig{Strategy: "null"},
+			expectedValue: nil,
+		},
+	}
+
+	for _, test := range testCases {
+		fieldMask, err := buildMask(test.maskConfig, keys)
+		if err != nil {
+			t.Fatalf("for test %s failed to build mask %s", test.testName, err)
+		}
+
+		masked := fieldMask.Mask("john@domain.com", mask.FieldTypeString)
+		if masked != test.expectedValue {
+			t.Fatalf("for test %s expected %#v got %#v instead", test.testName, test.expectedValue, masked)
+		}
+	}
+}
+
+func TestBuildMaskTokens(t *testing.T) {
+	keys := map[string][]byte{
+		"k1": []byte("0123456789abcdef0123456789abcdef"),
+	}
+
+	hmacMask, err := buildMask(config.MaskConfig{Strategy: "hmac", KeyID: "k1", Prefix: "tok_", Length: 8}, keys)
+	if err != nil {
+		t.Fatalf("failed to build hmac mask %s", err)
+	}
+
+	first := hmacMask.Mask("john@domain.com", mask.FieldTypeString)
+	second := hmacMask.Mask("john@domain.com", mask.FieldTypeString)
+
+	token, ok := first.(string)
+	if !ok || !strings.HasPrefix(token, "tok_k1.") || first != second {
+		t.Fatalf("expected same prefixed token for same value got %#v and %#v", first, second)
+	}
+
+	encryptMask, err := buildMask(config.MaskConfig{Strategy: "encrypt", KeyID: "k1"}, keys)
+	if err != nil {
+		t.Fatalf("failed to build encrypt mask %s", err)
+	}
+
+	if encryptMask.Mask("john@domain.com", mask.FieldTypeString) == "john@domain.com" {
+		t.Fatalf("expected encrypt mask to replace value")
+	}
+}
+
+func TestBuildMaskError(t *testing.T) {
+	keys := map[string][]byte{
+		"k1": []byte("0123456789abcdef0123456789abcdef"),
+	}
+
+	testCases := []struct {
+		testName      string
+		maskConfig    config.MaskConfig
+		expectedError error
+	}{
+		{
+			testName:      "unknown_strategy",
+			maskConfig:    config.MaskConfig{Strategy: "shuffle"},
+			expectedError: ErrUnknownMaskStrategy,
+		},
+		{
+			testName:      "multiple_chars",
+			maskConfig:    config.MaskConfig{Strategy: "keep_last", Char: "**"},
+			expectedError: ErrMaskChar,
+		},
+		{
+			testName:      "hmac_unknown_key",
+			maskConfig:    config.MaskConfig{Strategy: "hmac", KeyID: "k2"},
+			expectedError: mask.ErrUnknownKeyID,
+		},
+		{
+			testName:      "encrypt_unknown_key",
+			maskConfig:    config.MaskConfig{Strategy: "encrypt", KeyID: "k2"},
+			expectedError: mask.ErrUnknownKeyID,
+		},
+	}
+
+	for _, test := range testCases {
+		_, err := buildMask(test.maskConfig, keys)
+		if !errors.Is(err, test.expectedError) {
+			t.Fatalf("for test %s expected %s got %v instead", test.testName, test.expectedError, err)
+		}
+	}
+}
+
+func TestBuildMaskingRules(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		maskingConfig config.MaskingConfig
+		body          string
+		expectedBody  string
+	}{
+		{
+			testName:      "default_rule_classifies_by_name_only",
+			maskingConfig: config.MaskingConfig{},
+			body:          `{"name":"mark","contact":"john@domain.com","order_id":1000000000000008}`,
+			expectedBody:  `{"name":"x","contact":"john@domain.com","order_id":1000000000000008}`,
+		},
+		{
+			testName:      "default_rule_with_exclude",
+			maskingConfig: config.MaskingConfig{Exclude: []string{"$.product.name"}},
+			body:          `{"name":"mark","product":{"name":"chair"}}`,
+			expectedBody:  `{"name":"x","product":{"name":"chair"}}`,
+		},
+		{
+			testName: "rules_by_value_and_path",
+			maskingConfig: config.MaskingConfig{
+				Rules: []config.MaskingRuleConfig{
+					{Values: []string{"email"}},
+					{Paths: []string{"$.users[*].id"}, Types: []string{"float64"}},
+				},
+			},
+			body:         `{"contact":"john@domain.com","users":[{"id":7,"name":"mark"}]}`,
+			expectedBody: `{"contact":"x","users":[{"id":0,"name":"mark"}]}`,
+		},
+		{
+			testName: "top_level_exclude_applies_to_every_rule",
+			maskingConfig: config.MaskingConfig{
+				Exclude: []string{"$.admin.email"},
+				Rules: []config.MaskingRuleConfig{
+					{Field: "email"},
+				},
+			},
+			body:         `{"email":"john@domain.com","admin":{"email":"admin@domain.com"}}`,
+			expectedBody: `{"email":"x","admin":{"email":"admin@domain.com"}}`,
+		},
+	}
+
+	for _, test := range testCases {
+		rules, err := buildMaskingRules(test.maskingConfig)
+		if err != nil {
+			t.Fatalf("for test %s failed to build rules %s", test.testName, err)
+		}
+
+		masked, err := mask.NewJSONRulesInspector(rules).Inspect([]byte(test.body))
+		if err != nil {
+			t.Fatalf("for test %s failed to mask body %s", test.testName, err)
+		}
+
+		if string(masked) != test.expectedBody {
+			t.Fatalf("for test %s expected %s got %s instead", test.testName, test.expectedBody, masked)
+		}
+	}
+}
+
+func TestBuildMaskingRulesError(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		maskingConfig config.MaskingConfig
+		expectedError error
+	}{
+		{
+			testName:      "invalid_exclude",
+			maskingConfig: config.MaskingConfig{Exclude: []string{"product.name"}},
+			expectedError: mask.ErrInvalidPath,
+		},
+		{
+			testName:      "empty_rule",
+			maskingConfig: config.MaskingConfig{Rules: []config.MaskingRuleConfig{{Types: []string{"string"}}}},
+			expectedError: ErrEmptyMaskingRule,
+		},
+		{
+			testName:      "unknown_value_pattern",
+			maskingConfig: config.MaskingConfig{Rules: []config.MaskingRuleConfig{{Values: []string{"passport"}}}},
+			expectedError: mask.ErrUnknownValuePattern,
+		},
+		{
+			testName:      "invalid_path",
+			maskingConfig: config.MaskingConfig{Rules: []config.MaskingRuleConfig{{Paths: []string{"$.users["}}}},
+			expectedError: mask.ErrInvalidPath,
+		},
+		{
+			testName:      "unknown_type",
+			maskingConfig: config.MaskingConfig{Rules: []config.MaskingRuleConfig{{Field: "name", Types: []string{"int"}}}},
+			expectedError: mask.ErrUnknownFieldType,
+		},
+		{
+			testName: "unknown_strategy",
+			maskingConfig: config.MaskingConfig{Rules: []config.MaskingRuleConfig{
+				{Field: "name", Mask: config.MaskConfig{Strategy: "shuffle"}},
+			}},
+			expectedError: ErrUnknownMaskStrategy,
+		},
+		{
+			testName: "bad_char",
+			maskingConfig: config.MaskingConfig{Rules: []config.MaskingRuleConfig{
+				{Field: "name", Mask: config.MaskConfig{Strategy: "substitute", Char: "ab"}},
+			}},
+			expectedError: ErrMaskChar,
+		},
+		{
+			testName: "missing_key",
+			maskingConfig: config.MaskingConfig{
+				Keys: map[string]config.MaskingKeyConfig{"k1": {Env: "MASKING_TEST_MISSING_KEY"}},
+				Rules: []config.MaskingRuleConfig{
+					{Field: "name", Mask: config.MaskConfig{Strategy: "hmac", KeyID: "k1"}},
+				},
+			},
+			expectedError: ErrMaskingKey,
+		},
+	}
+
+	for _, test := range testCases {
+		_, err := buildMaskingRules(test.maskingConfig)
+		if !errors.Is(err, test.expectedError) {
+			t.Fatalf("for test %s expected %s got %v instead", test.testName, test.expectedError, err)
+		}
+	}
+
+	_, err := buildMaskingRules(config.MaskingConfig{Rules: []config.MaskingRuleConfig{
+		{Field: "name"},
+		{Values: []string{"passport"}},
+	}})
+	if err == nil || !strings.HasPrefix(err.Error(), "rule 1: ") {
+		t.Fatalf("expected error to identify rule by its index got %v instead", err)
+	}
+}
+
+func TestLoadMaskingKeys(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	encoded := base64.StdEncoding.EncodeToString(key)
+
+	t.Setenv("MASKING_TEST_KEY", encoded)
+
+	keyFile := filepath.Join(t.TempDir(), "key")
+
+	err := os.WriteFile(keyFile, []byte(encoded+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := loadMaskingKeys(map[string]config.MaskingKeyConfig{
+		"env":  {Env: "MASKING_TEST_KEY"},
+		"file": {File: keyFile},
+	})
+	if err != nil {
+		t.Fatalf("failed to load keys %s", err)
+	}
+
+	for _, keyID := range []string{"env", "file"} {
+		if string(keys[keyID]) != string(key) {
+			t.Fatalf("expected key %s to be loaded got %q instead", keyID, keys[keyID])
+		}
+	}
+}
+
+func TestLoadMaskingKeysError(t *testing.T) {
+	t.Setenv("MASKING_TEST_INVALID_KEY", "not base64!")
+	t.Setenv("MASKING_TEST_EMPTY_KEY", "")
+
+	emptyFile := filepath.Join(t.TempDir(), "empty")
+
+	err := os.WriteFile(emptyFile, []byte("\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		testName  string
+		keyConfig config.MaskingKeyConfig
+	}{
+		{
+			testName:  "missing_env",
+			keyConfig: config.MaskingKeyConfig{Env: "MASKING_TEST_MISSING_KEY"},
+		},
+		{
+			testName:  "empty_env",
+			keyConfig: config.MaskingKeyConfig{Env: "MASKING_TEST_EMPTY_KEY"},
+		},
+		{
+			testName:  "invalid_base64",
+			keyConfig: config.MaskingKeyConfig{Env: "MASKING_TEST_INVALID_KEY"},
+		},
+		{
+			testName:  "missing_file",
+			keyConfig: config.MaskingKeyConfig{File: filepath.Join(t.TempDir(), "missing")},
+		},
+		{
+			testName:  "empty_file",
+			keyConfig: config.MaskingKeyConfig{File: emptyFile},
+		},
+	}
+
+	for _, test := range testCases {
+		_, err := loadMaskingKeys(map[string]config.MaskingKeyConfig{"k1": test.keyConfig})
+		if !errors.Is(err, ErrMaskingKey) {
+			t.Fatalf("for test %s expected %s got %v instead", test.testName, ErrMaskingKey, err)
+		}
+	}
+}
+
+func TestBuildInspectors(t *testing.T) {
+	registry, err := buildInspectors(config.MaskingConfig{
+		ContentTypes: map[string]string{"text/plain": "json"},
+	})
+	if err != nil {
+		t.Fatalf("failed to build inspectors %s", err)
+	}
+
+	for _, contentType := range []string{"application/json", "text/event-stream", "text/plain; charset=utf-8"} {
+		_, ok := registry.Lookup(contentType)
+		if !ok {
+			t.Fatalf("expected inspector for content type %s", contentType)
+		}
+	}
+
+	_, err = buildInspectors(config.MaskingConfig{
+		ContentTypes: map[string]string{"text/plain": "yaml"},
+	})
+	if !errors.Is(err, ErrUnknownContentSyntax) {
+		t.Fatalf("expected %s got %v instead", ErrUnknownContentSyntax, err)
+	}
+}
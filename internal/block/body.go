@@ -0,0 +1,212 @@
+package block
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/vjerci/reverse-proxy/internal/mask"
+)
+
+// BodyFieldGuard blocks request if any json body value at Field path matches, values are matched exactly unless match mode is set.
+// Field is a json path like "$.role" or "$.users[*].role", strings are matched by their content and numbers, booleans and null
+// by their json text. Objects and arrays match only "present" mode. Bodies which aren't valid json don't block
+type BodyFieldGuard struct {
+	Field   string `mapstructure:"body_field"`
+	Value   string `mapstructure:"value"`
+	Matcher `mapstructure:",squash"`
+
+	path *mask.PathPattern
+}
+
+func (guard *BodyFieldGuard) ShouldBlock(req *http.Request) bool {
+	path := guard.path
+	if path == nil {
+		var err error
+
+		path, err = mask.ParsePathPattern(guard.Field)
+		if err != nil {
+			return false
+		}
+	}
+
+	body, ok := requestBody(req)
+	if !ok || len(body) == 0 {
+		return false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	// errors only stop the walk, values matched before them still block
+	found, _ := walkJSON(decoder, mask.Path{}, func(valuePath mask.Path, token json.Token) bool {
+		return path.Match(valuePath) && guard.matchesToken(token)
+	})
+
+	return found
+}
+
+func (guard *BodyFieldGuard) matchesToken(token json.Token) bool {
+	var value string
+
+	switch typed := token.(type) {
+	case json.Delim:
+		return guard.mode(MatchExact) == MatchPresent
+	case string:
+		value = typed
+	case json.Number:
+		value = typed.String()
+	case bool:
+		value = strconv.FormatBool(typed)
+	case nil:
+		value = "null"
+	}
+
+	return guard.matches(guard.Value, value, MatchExact, "")
+}
+
+func (guard *BodyFieldGuard) IsValid() bool {
+	return guard.Field != "" && (guard.Value != "" || guard.Mode == MatchPresent)
+}
+
+func (guard *BodyFieldGuard) NeedsBody() bool {
+	return true
+}
+
+func (guard *BodyFieldGuard) compile() error {
+	path, err := mask.ParsePathPattern(guard.Field)
+	if err != nil {
+		return err
+	}
+
+	guard.path = path
+
+	return guard.Matcher.compile(guard.Value, MatchExact, "")
+}
+
+// walkJSON visits every value of document with its path, containers are visited before their elements.
+// Every occurrence of duplicated key is visited, so payload can't hide value behind another one with the same key
+func walkJSON(decoder *json.Decoder, path mask.Path, visit func(path mask.Path, token json.Token) bool) (bool, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return false, err
+	}
+
+	if visit(path, token) {
+		return true, nil
+	}
+
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return false, nil
+	}
+
+	for index := 0; decoder.More(); index++ {
+		segment := mask.PathSegment{Index: index, IsIndex: true}
+
+		if delim == '{' {
+			key, err := decoder.Token()
+			if err != nil {
+				return false, err
+			}
+
+			segment = mask.PathSegment{Key: fmt.Sprint(key)}
+		}
+
+		found, err := walkJSON(decoder, append(path, segment), visit)
+		if err != nil || found {
+			return found, err
+		}
+	}
+
+	// closing delimiter
+	_, err = decoder.Token()
+
+	return false, err
+}
+
+// BodyContentGuard blocks request if its raw body matches, body is matched by regex unless match mode is set
+type BodyContentGuard struct {
+	Body    string `mapstructure:"body"`
+	Matcher `mapstructure:",squash"`
+}
+
+func (guard *BodyContentGuard) ShouldBlock(req *http.Request) bool {
+	body, ok := requestBody(req)
+	if !ok {
+		return false
+	}
+
+	return guard.matches(guard.Body, string(body), MatchRegex, "")
+}
+
+func (guard *BodyContentGuard) IsValid() bool {
+	return guard.Body != ""
+}
+
+func (guard *BodyContentGuard) NeedsBody() bool {
+	return true
+}
+
+func (guard *BodyContentGuard) compile() error {
+	// use body size guard to block requests by having a body
+	if guard.Mode == MatchPresent {
+		return fmt.Errorf("%w: %s for body", ErrUnknownMatchMode, guard.Mode)
+	}
+
+	return guard.Matcher.compile(guard.Body, MatchRegex, "")
+}
+
+// BodySizeGuard blocks request whose body is larger than MaxBodySize bytes. Size is taken from Content-Length,
+// body of unknown length is read only up to the limit and put back in front of the rest, so it can still be streamed
+type BodySizeGuard struct {
+	MaxBodySize int64 `mapstructure:"max_body_size"`
+}
+
+func (guard *BodySizeGuard) ShouldBlock(req *http.Request) bool {
+	if req.ContentLength >= 0 || req.Body == nil {
+		return req.ContentLength > guard.MaxBodySize
+	}
+
+	head, err := io.ReadAll(io.LimitReader(req.Body, guard.MaxBodySize+1))
+
+	req.Body = &prefixedBody{
+		Reader: io.MultiReader(bytes.NewReader(head), req.Body),
+		Closer: req.Body,
+	}
+
+	return err == nil && int64(len(head)) > guard.MaxBodySize
+}
+
+func (guard *BodySizeGuard) IsValid() bool {
+	return guard.MaxBodySize > 0
+}
+
+// prefixedBody is request body with part already read put back in front of it
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// requestBody reads body buffered before guards are called, requests whose body wasn't buffered have none
+func requestBody(req *http.Request) ([]byte, bool) {
+	if req.GetBody == nil {
+		return nil, false
+	}
+
+	reader, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	defer reader.Close()
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false
+	}
+
+	return body, true
+}
@@ -0,0 +1,264 @@
+package block_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/vjerci/reverse-proxy/internal/block"
+	"github.com/vjerci/reverse-proxy/internal/mask"
+)
+
+func TestBodyGuards(t *testing.T) {
+	decoder := &block.InterfaceGuardDecoder{}
+
+	roleRule := map[string]interface{}{"body_field": "$.role", "value": "admin"}
+
+	testCases := []struct {
+		testName string
+		rule     map[string]interface{}
+		body     string
+		block    bool
+	}{
+		{
+			testName: "field_matches",
+			rule:     roleRule,
+			body:     `{"name": "john", "role": "admin"}`,
+			block:    true,
+		},
+		{
+			testName: "field_differs",
+			rule:     roleRule,
+			body:     `{"name": "john", "role": "user"}`,
+			block:    false,
+		},
+		{
+			testName: "nested_field_does_not_match_top_level_path",
+			rule:     roleRule,
+			body:     `{"user": {"role": "admin"}}`,
+			block:    false,
+		},
+		{
+			testName: "duplicated_key",
+			rule:     roleRule,
+			body:     `{"role": "admin", "role": "user"}`,
+			block:    true,
+		},
+		{
+			testName: "invalid_json",
+			rule:     roleRule,
+			body:     `role=admin`,
+			block:    false,
+		},
+		{
+			testName: "wildcard_path",
+			rule:     map[string]interface{}{"body_field": "$.users[*].role", "value": "ADMIN", "ignore_case": true},
+			body:     `{"users": [{"role": "user"}, {"role": "admin"}]}`,
+			block:    true,
+		},
+		{
+			testName: "recursive_path",
+			rule:     map[string]interface{}{"body_field": "$..is_admin", "value": "true"},
+			body:     `{"profile": {"settings": {"is_admin": true}}}`,
+			block:    true,
+		},
+		{
+			testName: "number_field",
+			rule:     map[string]interface{}{"body_field": "$.level", "value": "^9\\d*$", "match": "regex"},
+			body:     `{"level": 99}`,
+			block:    true,
+		},
+		{
+			testName: "present_object",
+			rule:     map[string]interface{}{"body_field": "$.permissions", "match": "present"},
+			body:     `{"permissions": {"write": true}}`,
+			block:    true,
+		},
+		{
+			testName: "object_does_not_match_value",
+			rule:     map[string]interface{}{"body_field": "$.permissions", "value": "{}"},
+			body:     `{"permissions": {}}`,
+			block:    false,
+		},
+		{
+			testName: "body_regex",
+			rule:     map[string]interface{}{"body": "(?i)<script"},
+			body:     `<p>hi</p><SCRIPT>alert(1)</SCRIPT>`,
+			block:    true,
+		},
+		{
+			testName: "body_regex_no_match",
+			rule:     map[string]interface{}{"body": "(?i)<script"},
+			body:     `<p>hi</p>`,
+			block:    false,
+		},
+		{
+			testName: "body_contains",
+			rule:     map[string]interface{}{"body": "DROP TABLE", "match": "contains"},
+			body:     `name=x'; DROP TABLE users`,
+			block:    true,
+		},
+	}
+
+	for _, test := range testCases {
+		guard, err := decoder.Decode(test.rule)
+		if err != nil {
+			t.Fatalf("for test %s failed to decode guard %s", test.testName, err)
+		}
+
+		if !block.NeedsBody(guard) {
+			t.Fatalf("for test %s expected guard to need body", test.testName)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "http://domain.com/users", strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if guard.ShouldBlock(req) != test.block {
+			t.Fatalf("%s test case failed, expected outcome %t", test.testName, test.block)
+		}
+
+		// body stays readable for guards after it
+		if guard.ShouldBlock(req) != test.block {
+			t.Fatalf("%s test case failed on second check, expected outcome %t", test.testName, test.block)
+		}
+	}
+}
+
+func TestBodySizeGuard(t *testing.T) {
+	guard, err := (&block.InterfaceGuardDecoder{}).Decode(map[string]interface{}{"max_body_size": float64(4)})
+	if err != nil {
+		t.Fatalf("failed to decode guard %s", err)
+	}
+
+	if block.NeedsBody(guard) {
+		t.Fatalf("expected body size guard not to need buffered body")
+	}
+
+	testCases := []struct {
+		testName      string
+		body          string
+		contentLength int64
+		block         bool
+	}{
+		{
+			testName:      "known_length_over_size",
+			body:          "12345",
+			contentLength: 5,
+			block:         true,
+		},
+		{
+			testName:      "known_length_within_size",
+			body:          "1234",
+			contentLength: 4,
+			block:         false,
+		},
+		{
+			testName:      "unknown_length_over_size",
+			body:          "1234567890",
+			contentLength: -1,
+			block:         true,
+		},
+		{
+			testName:      "unknown_length_within_size",
+			body:          "1234",
+			contentLength: -1,
+			block:         false,
+		},
+	}
+
+	for _, test := range testCases {
+		body := &CountingReaderMock{reader: strings.NewReader(test.body)}
+
+		req, err := http.NewRequest(http.MethodPost, "http://domain.com/upload", io.NopCloser(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.ContentLength = test.contentLength
+
+		if guard.ShouldBlock(req) != test.block {
+			t.Fatalf("%s test case failed, expected outcome %t", test.testName, test.block)
+		}
+
+		if body.read > 5 {
+			t.Fatalf("%s test case read %d bytes, expected at most limit and one byte", test.testName, body.read)
+		}
+
+		// body stays whole for forwarding
+		forwarded, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(forwarded) != test.body {
+			t.Fatalf("%s test case expected body %s to be kept got %s instead", test.testName, test.body, forwarded)
+		}
+	}
+}
+
+type CountingReaderMock struct {
+	reader io.Reader
+	read   int
+}
+
+func (mock *CountingReaderMock) Read(p []byte) (int, error) {
+	n, err := mock.reader.Read(p)
+	mock.read += n
+
+	return n, err
+}
+
+func TestBodyGuardWithoutBufferedBody(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "http://domain.com/users", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.GetBody = nil
+	req.ContentLength = 100
+
+	if (&block.BodyFieldGuard{Field: "$.role", Value: "admin"}).ShouldBlock(req) {
+		t.Fatalf("expected field guard not to block request without buffered body")
+	}
+
+	if !(&block.BodySizeGuard{MaxBodySize: 10}).ShouldBlock(req) {
+		t.Fatalf("expected size guard to fall back to content length")
+	}
+}
+
+func TestBodyGuardDecodeError(t *testing.T) {
+	decoder := &block.InterfaceGuardDecoder{}
+
+	testCases := []struct {
+		testName      string
+		rule          map[string]interface{}
+		expectedError error
+	}{
+		{
+			testName:      "invalid_field_path",
+			rule:          map[string]interface{}{"body_field": "role", "value": "admin"},
+			expectedError: mask.ErrInvalidPath,
+		},
+		{
+			testName:      "invalid_body_regex",
+			rule:          map[string]interface{}{"body": "(unclosed"},
+			expectedError: block.ErrInvalidMatchPattern,
+		},
+		{
+			testName:      "present_body",
+			rule:          map[string]interface{}{"body": "x", "match": "present"},
+			expectedError: block.ErrUnknownMatchMode,
+		},
+	}
+
+	for _, test := range testCases {
+		_, err := decoder.Decode(test.rule)
+		if !errors.Is(err, block.ErrDecodeGuard) || !errors.Is(err, test.expectedError) {
+			t.Fatalf("for test %s expected %s got %s instead", test.testName, test.expectedError, err)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 var ErrDecodeJSON = errors.New("failed to decode json of your rules")
 var ErrDecodeMapStructure = errors.New("failed to decode mapstructure")
 var ErrDecodeGuard = errors.New("failed to decode guard")
+var ErrDecodeRule = errors.New("failed to decode rule")
 
 type GuardDecoder interface {
 	Decode(interface{}) (DecodedGuard, error)
@@ -25,7 +26,7 @@ func (decoder *InterfaceGuardDecoder) Decode(input interface{}) (DecodedGuard, e
 	}
 
 	if headerGuard.IsValid() {
-		return &headerGuard, nil
+		return compiled(&headerGuard)
 	}
 
 	var queryParamGuard QueryParamGuard
@@ -35,7 +36,7 @@ func (decoder *InterfaceGuardDecoder) Decode(input interface{}) (DecodedGuard, e
 	}
 
 	if queryParamGuard.IsValid() {
-		return &queryParamGuard, nil
+		return compiled(&queryParamGuard)
 	}
 
 	var methodGuard MethodGuard
@@ -55,28 +56,161 @@ func (decoder *InterfaceGuardDecoder) Decode(input interface{}) (DecodedGuard, e
 	}
 
 	if pathGuard.IsValid() {
-		return &pathGuard, nil
+		return compiled(&pathGuard)
+	}
+
+	var ipGuard IPGuard
+	err = mapstructure.Decode(input, &ipGuard)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecodeMapStructure, err)
+	}
+
+	if ipGuard.IsValid() {
+		return compiled(&ipGuard)
+	}
+
+	var rateLimitGuard RateLimitGuard
+	err = mapstructure.Decode(input, &rateLimitGuard)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecodeMapStructure, err)
+	}
+
+	if rateLimitGuard.IsValid() {
+		return compiled(&rateLimitGuard)
+	}
+
+	var bodyFieldGuard BodyFieldGuard
+	err = mapstructure.Decode(input, &bodyFieldGuard)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecodeMapStructure, err)
+	}
+
+	if bodyFieldGuard.IsValid() {
+		return compiled(&bodyFieldGuard)
+	}
+
+	var bodyContentGuard BodyContentGuard
+	err = mapstructure.Decode(input, &bodyContentGuard)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecodeMapStructure, err)
+	}
+
+	if bodyContentGuard.IsValid() {
+		return compiled(&bodyContentGuard)
+	}
+
+	var bodySizeGuard BodySizeGuard
+	err = mapstructure.Decode(input, &bodySizeGuard)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecodeMapStructure, err)
+	}
+
+	if bodySizeGuard.IsValid() {
+		return &bodySizeGuard, nil
 	}
 
 	return nil, fmt.Errorf("%w : %#v", ErrDecodeGuard, input)
 }
 
-func GuardsFromInterface(jsonData [][]interface{}, decoder GuardDecoder) (Guard, error) {
-	var collections []Guard
-	for _, rulestToJoin := range jsonData {
-		joinedRules := []Guard{}
+type compilableGuard interface {
+	DecodedGuard
+	compile() error
+}
 
-		for _, rule := range rulestToJoin {
-			guard, err := decoder.Decode(rule)
+// compiled validates match mode and pattern of decoded guard
+func compiled(guard compilableGuard) (DecodedGuard, error) {
+	err := guard.compile()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecodeGuard, err)
+	}
+
+	return guard, nil
+}
+
+const nodeAny = "any"
+const nodeAll = "all"
+const nodeNot = "not"
+
+// GuardsFromInterface decodes rule tree. Object with single "any", "all" or "not" key is a node of the tree,
+// "any" blocks if any of its rules blocks, "all" if all of them block and "not" if its rule doesn't block.
+// Other objects are guards decoded by decoder. Arrays are shorthand kept from the former rule format,
+// top level array acts as "any" and arrays inside it act as "all", so [[a, b], [c]] blocks on (a && b) || c
+func GuardsFromInterface(jsonData interface{}, decoder GuardDecoder) (Guard, error) {
+	if jsonData == nil {
+		return NewGuardsCollection(nil), nil
+	}
+
+	rules, isArray := jsonData.([]interface{})
+	if !isArray {
+		return decodeNode(jsonData, decoder)
+	}
+
+	guards, err := decodeNodes(rules, decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewGuardsCollection(guards), nil
+}
+
+func decodeNode(node interface{}, decoder GuardDecoder) (Guard, error) {
+	rules, isArray := node.([]interface{})
+	if isArray {
+		guards, err := decodeNodes(rules, decoder)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewGuardsJoiner(guards), nil
+	}
+
+	object, isObject := node.(map[string]interface{})
+	if !isObject || len(object) != 1 {
+		return decoder.Decode(node)
+	}
+
+	for key, value := range object {
+		switch key {
+		case nodeAny, nodeAll:
+			rules, isArray := value.([]interface{})
+			if !isArray {
+				return nil, fmt.Errorf("%w: %s needs array of rules got %#v", ErrDecodeRule, key, value)
+			}
+
+			guards, err := decodeNodes(rules, decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			if key == nodeAny {
+				return NewGuardsCollection(guards), nil
+			}
+
+			return NewGuardsJoiner(guards), nil
+		case nodeNot:
+			guard, err := decodeNode(value, decoder)
 			if err != nil {
 				return nil, err
 			}
 
-			joinedRules = append(joinedRules, guard)
+			return NewNotGuard(guard), nil
+		}
+	}
+
+	return decoder.Decode(node)
+}
+
+func decodeNodes(rules []interface{}, decoder GuardDecoder) ([]Guard, error) {
+	guards := make([]Guard, 0, len(rules))
+
+	for _, rule := range rules {
+		guard, err := decodeNode(rule, decoder)
+		if err != nil {
+			return nil, err
 		}
 
-		collections = append(collections, NewGuardsJoiner(joinedRules))
+		guards = append(guards, guard)
 	}
 
-	return NewGuardsCollection(collections), nil
+	return guards, nil
 }
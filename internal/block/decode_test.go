@@ -1,8 +1,11 @@
 package block_test
 
 import (
+	"encoding/json"
 	"errors"
+	"net/http"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/vjerci/reverse-proxy/internal/block"
@@ -79,3 +82,156 @@ func TestInterfaceGuardDecoderError(t *testing.T) {
 		t.Fatalf("expected ErrFailedToDecodeGuard")
 	}
 }
+
+func TestInterfaceGuardDecoderMatchError(t *testing.T) {
+	decoder := &block.InterfaceGuardDecoder{}
+
+	testCases := []struct {
+		testName      string
+		input         interface{}
+		expectedError error
+	}{
+		{
+			testName: "unknown_mode",
+			input: map[string]interface{}{
+				"path":  "/api",
+				"match": "fuzzy",
+			},
+			expectedError: block.ErrUnknownMatchMode,
+		},
+		{
+			testName: "invalid_regex",
+			input: map[string]interface{}{
+				"header": "User-Agent",
+				"value":  "sqlmap(",
+				"match":  "regex",
+			},
+			expectedError: block.ErrInvalidMatchPattern,
+		},
+	}
+
+	for _, test := range testCases {
+		value, err := decoder.Decode(test.input)
+
+		if value != nil {
+			t.Fatalf("for test %s expected nil value but got %#v instead", test.testName, value)
+		}
+
+		if !errors.Is(err, block.ErrDecodeGuard) || !errors.Is(err, test.expectedError) {
+			t.Fatalf("for test %s expected %s got %s instead", test.testName, test.expectedError, err)
+		}
+	}
+}
+
+func TestGuardsFromInterface(t *testing.T) {
+	deleteRequest, err := http.NewRequest(http.MethodDelete, "http://domain.com/api/users", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adminDeleteRequest, err := http.NewRequest(http.MethodDelete, "http://domain.com/api/users", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminDeleteRequest.Header.Set("X-Admin", "1")
+
+	getRequest, err := http.NewRequest(http.MethodGet, "http://domain.com/api/users", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		testName string
+		rules    string
+		block    []bool
+	}{
+		{
+			testName: "shorthand",
+			rules:    `[[{"method": "DELETE"}, {"path": "/api"}], [{"method": "POST"}]]`,
+			block:    []bool{true, true, false},
+		},
+		{
+			testName: "delete_unless_admin",
+			rules:    `{"all": [{"method": "DELETE"}, {"not": {"header": "X-Admin", "match": "present"}}]}`,
+			block:    []bool{true, false, false},
+		},
+		{
+			testName: "nested_nodes",
+			rules:    `[{"any": [{"all": [{"not": {"method": "DELETE"}}, {"path": "/api"}]}, {"header": "X-Admin", "value": "1"}]}]`,
+			block:    []bool{false, true, true},
+		},
+		{
+			testName: "double_negation",
+			rules:    `{"not": {"not": {"method": "GET"}}}`,
+			block:    []bool{false, false, true},
+		},
+		{
+			testName: "empty",
+			rules:    `[]`,
+			block:    []bool{false, false, false},
+		},
+		{
+			testName: "missing",
+			rules:    `null`,
+			block:    []bool{false, false, false},
+		},
+	}
+
+	for _, test := range testCases {
+		var rules interface{}
+
+		err := json.Unmarshal([]byte(test.rules), &rules)
+		if err != nil {
+			t.Fatalf("for test %s failed to unmarshal rules %s", test.testName, err)
+		}
+
+		guard, err := block.GuardsFromInterface(rules, &block.InterfaceGuardDecoder{})
+		if err != nil {
+			t.Fatalf("for test %s failed to decode rules %s", test.testName, err)
+		}
+
+		for i, req := range []*http.Request{deleteRequest, adminDeleteRequest, getRequest} {
+			if guard.ShouldBlock(req) != test.block[i] {
+				t.Fatalf("for test %s request %d expected outcome %t", test.testName, i, test.block[i])
+			}
+		}
+	}
+}
+
+func TestGuardsFromInterfaceError(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		rules         string
+		expectedError error
+	}{
+		{
+			testName:      "any_without_array",
+			rules:         `{"any": {"method": "GET"}}`,
+			expectedError: block.ErrDecodeRule,
+		},
+		{
+			testName:      "unknown_guard_in_not",
+			rules:         `{"not": {"unknown": "GET"}}`,
+			expectedError: block.ErrDecodeGuard,
+		},
+		{
+			testName:      "unknown_guard_in_shorthand",
+			rules:         `[[{"method": "GET"}, {"unknown": "GET"}]]`,
+			expectedError: block.ErrDecodeGuard,
+		},
+	}
+
+	for _, test := range testCases {
+		var rules interface{}
+
+		err := json.Unmarshal([]byte(test.rules), &rules)
+		if err != nil {
+			t.Fatalf("for test %s failed to unmarshal rules %s", test.testName, err)
+		}
+
+		_, err = block.GuardsFromInterface(rules, &block.InterfaceGuardDecoder{})
+		if !errors.Is(err, test.expectedError) {
+			t.Fatalf("for test %s expected %s got %s instead", test.testName, test.expectedError, err)
+		}
+	}
+}
@@ -2,10 +2,13 @@
 package block
 
 import (
+	"fmt"
 	"net/http"
-	"strings"
 )
 
+// glob wildcards in paths don't cross path segments
+const pathSeparator = "/"
+
 type Guard interface {
 	ShouldBlock(req *http.Request) bool
 }
@@ -15,30 +18,75 @@ type DecodedGuard interface {
 	IsValid() bool
 }
 
+// BodyGuard is implemented by guards which need request body to be buffered before ShouldBlock is called
+type BodyGuard interface {
+	NeedsBody() bool
+}
+
+func NeedsBody(guard Guard) bool {
+	bodyGuard, ok := guard.(BodyGuard)
+
+	return ok && bodyGuard.NeedsBody()
+}
+
+func anyNeedsBody(guards []Guard) bool {
+	for _, guard := range guards {
+		if NeedsBody(guard) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HeaderGuard blocks request if any value of header matches, values are matched exactly unless match mode is set
 type HeaderGuard struct {
-	Header string `mapstructure:"header"`
-	Value  string `mapstructure:"value"`
+	Header  string `mapstructure:"header"`
+	Value   string `mapstructure:"value"`
+	Matcher `mapstructure:",squash"`
 }
 
 func (guard *HeaderGuard) ShouldBlock(req *http.Request) bool {
-	return req.Header.Get(guard.Header) == guard.Value
+	for _, value := range req.Header.Values(guard.Header) {
+		if guard.matches(guard.Value, value, MatchExact, "") {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (guard *HeaderGuard) IsValid() bool {
-	return guard.Header != "" && guard.Value != ""
+	return guard.Header != "" && (guard.Value != "" || guard.Mode == MatchPresent)
 }
 
+func (guard *HeaderGuard) compile() error {
+	return guard.Matcher.compile(guard.Value, MatchExact, "")
+}
+
+// QueryParamGuard blocks request if any value of query param matches, values are matched exactly unless match mode is set
 type QueryParamGuard struct {
 	QueryParam string `mapstructure:"query_param"`
 	Value      string `mapstructure:"value"`
+	Matcher    `mapstructure:",squash"`
 }
 
 func (guard *QueryParamGuard) ShouldBlock(req *http.Request) bool {
-	return req.URL.Query().Get(guard.QueryParam) == guard.QueryParam
+	for _, value := range req.URL.Query()[guard.QueryParam] {
+		if guard.matches(guard.Value, value, MatchExact, "") {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (guard *QueryParamGuard) IsValid() bool {
-	return guard.QueryParam != "" && guard.Value != ""
+	return guard.QueryParam != "" && (guard.Value != "" || guard.Mode == MatchPresent)
+}
+
+func (guard *QueryParamGuard) compile() error {
+	return guard.Matcher.compile(guard.Value, MatchExact, "")
 }
 
 type MethodGuard struct {
@@ -53,18 +101,29 @@ func (guard *MethodGuard) IsValid() bool {
 	return guard.Method != ""
 }
 
+// PathGuard blocks request if its path matches, paths are matched by prefix unless match mode is set
 type PathGuard struct {
-	Path string `mapstructure:"path"`
+	Path    string `mapstructure:"path"`
+	Matcher `mapstructure:",squash"`
 }
 
 func (guard *PathGuard) ShouldBlock(req *http.Request) bool {
-	return strings.Index(req.URL.Path, guard.Path) == 0
+	return guard.matches(guard.Path, req.URL.Path, MatchPrefix, pathSeparator)
 }
 
 func (guard *PathGuard) IsValid() bool {
 	return guard.Path != ""
 }
 
+func (guard *PathGuard) compile() error {
+	// every request has a path
+	if guard.Mode == MatchPresent {
+		return fmt.Errorf("%w: %s for path", ErrUnknownMatchMode, guard.Mode)
+	}
+
+	return guard.Matcher.compile(guard.Path, MatchPrefix, pathSeparator)
+}
+
 // for guardscollecation if any guard blocks it results into blocking request
 type GuardsCollection struct {
 	guards []Guard
@@ -86,6 +145,10 @@ func (collection *GuardsCollection) ShouldBlock(req *http.Request) bool {
 	return false
 }
 
+func (collection *GuardsCollection) NeedsBody() bool {
+	return anyNeedsBody(collection.guards)
+}
+
 // for guards joiner each Guard must block to result into block request
 type GuardsJoiner struct {
 	guards []Guard
@@ -98,11 +161,40 @@ func NewGuardsJoiner(guards []Guard) Guard {
 }
 
 func (collection *GuardsJoiner) ShouldBlock(req *http.Request) bool {
+	restore := verdictCheckpoint(req)
+
 	for _, guard := range collection.guards {
 		if !guard.ShouldBlock(req) {
+			restore()
 			return false
 		}
 	}
 
 	return true
 }
+
+func (collection *GuardsJoiner) NeedsBody() bool {
+	return anyNeedsBody(collection.guards)
+}
+
+// NotGuard blocks request only if guard it wraps doesn't block it
+type NotGuard struct {
+	guard Guard
+}
+
+func NewNotGuard(guard Guard) Guard {
+	return &NotGuard{
+		guard: guard,
+	}
+}
+
+func (not *NotGuard) ShouldBlock(req *http.Request) bool {
+	restore := verdictCheckpoint(req)
+	defer restore()
+
+	return !not.guard.ShouldBlock(req)
+}
+
+func (not *NotGuard) NeedsBody() bool {
+	return NeedsBody(not.guard)
+}
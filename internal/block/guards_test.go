@@ -127,3 +127,196 @@ func TestGuardsJoiner(t *testing.T) {
 		}
 	}
 }
+
+type BodyGuardMock struct{}
+
+func (guard *BodyGuardMock) ShouldBlock(req *http.Request) bool {
+	return false
+}
+
+func (guard *BodyGuardMock) NeedsBody() bool {
+	return true
+}
+
+func TestNeedsBody(t *testing.T) {
+	methodGuard := &block.MethodGuard{
+		Method: http.MethodDelete,
+	}
+
+	testCases := []struct {
+		testName string
+		guard    block.Guard
+		expected bool
+	}{
+		{
+			testName: "plain_guard",
+			guard:    methodGuard,
+			expected: false,
+		},
+		{
+			testName: "collection_without_body_guards",
+			guard:    block.NewGuardsCollection([]block.Guard{block.NewGuardsJoiner([]block.Guard{methodGuard})}),
+			expected: false,
+		},
+		{
+			testName: "nested_body_guard",
+			guard:    block.NewGuardsCollection([]block.Guard{block.NewGuardsJoiner([]block.Guard{methodGuard, &BodyGuardMock{}})}),
+			expected: true,
+		},
+		{
+			testName: "negated_body_guard",
+			guard:    block.NewNotGuard(&BodyGuardMock{}),
+			expected: true,
+		},
+	}
+
+	for _, test := range testCases {
+		if block.NeedsBody(test.guard) != test.expected {
+			t.Fatalf("%s test case failed, expected outcome %t", test.testName, test.expected)
+		}
+	}
+}
+
+func TestGuardMatchModes(t *testing.T) {
+	decoder := &block.InterfaceGuardDecoder{}
+
+	testCases := []struct {
+		testName string
+		rule     map[string]interface{}
+		url      string
+		header   string
+		block    bool
+	}{
+		{
+			testName: "path_prefix_by_default",
+			rule:     map[string]interface{}{"path": "/api"},
+			url:      "http://domain.com/api/users",
+			block:    true,
+		},
+		{
+			testName: "path_exact",
+			rule:     map[string]interface{}{"path": "/api", "match": "exact"},
+			url:      "http://domain.com/api/users",
+			block:    false,
+		},
+		{
+			testName: "path_suffix",
+			rule:     map[string]interface{}{"path": ".php", "match": "suffix"},
+			url:      "http://domain.com/index.php",
+			block:    true,
+		},
+		{
+			testName: "path_glob",
+			rule:     map[string]interface{}{"path": "/api/*/admin", "match": "glob"},
+			url:      "http://domain.com/api/v1/admin",
+			block:    true,
+		},
+		{
+			testName: "path_glob_doesnt_cross_segments",
+			rule:     map[string]interface{}{"path": "/api/*/admin", "match": "glob"},
+			url:      "http://domain.com/api/v1/users/admin",
+			block:    false,
+		},
+		{
+			testName: "path_glob_double_star",
+			rule:     map[string]interface{}{"path": "/api/**/admin", "match": "glob"},
+			url:      "http://domain.com/api/v1/users/admin",
+			block:    true,
+		},
+		{
+			testName: "path_glob_ignore_case",
+			rule:     map[string]interface{}{"path": "/API/v?/admin", "match": "glob", "ignore_case": true},
+			url:      "http://domain.com/api/v1/admin",
+			block:    true,
+		},
+		{
+			testName: "header_exact_by_default",
+			rule:     map[string]interface{}{"header": "User-Agent", "value": "sqlmap"},
+			header:   "sqlmap/1.7",
+			block:    false,
+		},
+		{
+			testName: "header_regex",
+			rule:     map[string]interface{}{"header": "User-Agent", "value": `sqlmap|nikto`, "match": "regex", "ignore_case": true},
+			header:   "Mozilla SQLMap/1.7",
+			block:    true,
+		},
+		{
+			testName: "header_contains",
+			rule:     map[string]interface{}{"header": "User-Agent", "value": "sqlmap", "match": "contains"},
+			header:   "sqlmap/1.7",
+			block:    true,
+		},
+		{
+			testName: "header_glob_crosses_slash",
+			rule:     map[string]interface{}{"header": "User-Agent", "value": "*map/*", "match": "glob"},
+			header:   "sqlmap/1.7",
+			block:    true,
+		},
+		{
+			testName: "header_case_insensitive",
+			rule:     map[string]interface{}{"header": "User-Agent", "value": "SQLMAP/1.7", "match": "case_insensitive"},
+			header:   "sqlmap/1.7",
+			block:    true,
+		},
+		{
+			testName: "query_param_value",
+			rule:     map[string]interface{}{"query_param": "user", "value": "admin"},
+			url:      "http://domain.com/?user=mark&user=admin",
+			block:    true,
+		},
+		{
+			testName: "query_param_name_isnt_value",
+			rule:     map[string]interface{}{"query_param": "user", "value": "admin"},
+			url:      "http://domain.com/?user=user",
+			block:    false,
+		},
+		{
+			testName: "query_param_prefix",
+			rule:     map[string]interface{}{"query_param": "redirect", "value": "http://", "match": "prefix"},
+			url:      "http://domain.com/?redirect=http://evil.com",
+			block:    true,
+		},
+	}
+
+	for _, test := range testCases {
+		guard, err := decoder.Decode(test.rule)
+		if err != nil {
+			t.Fatalf("for test %s failed to decode guard %s", test.testName, err)
+		}
+
+		url := test.url
+		if url == "" {
+			url = "http://domain.com/"
+		}
+
+		req, err := http.NewRequest(http.MethodGet, url, strings.NewReader(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("User-Agent", test.header)
+
+		if guard.ShouldBlock(req) != test.block {
+			t.Fatalf("%s test case failed, expected outcome %t", test.testName, test.block)
+		}
+	}
+}
+
+func TestGuardMatchModeWithoutDecoder(t *testing.T) {
+	guard := &block.PathGuard{
+		Path: "/api/*/admin",
+		Matcher: block.Matcher{
+			Mode: block.MatchGlob,
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://domain.com/api/v1/admin", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !guard.ShouldBlock(req) {
+		t.Fatalf("expected guard built without decoder to match glob")
+	}
+}
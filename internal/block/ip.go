@@ -0,0 +1,225 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+var ErrInvalidCIDR = errors.New("invalid ip address or cidr range")
+var ErrUnknownForwardedHeader = errors.New("unknown forwarded header")
+
+const headerXForwardedFor = "X-Forwarded-For"
+const headerForwarded = "Forwarded"
+
+// IPGuard blocks clients whose address is in Deny list, or isn't in Allow list when Allow list is set.
+// Lists hold IPv4 and IPv6 addresses and cidr ranges. Client address is req.RemoteAddr, unless it is one of TrustedProxies,
+// then client is the first address from the right of ForwardedHeader chain which isn't trusted proxy.
+// ForwardedHeader is "X-Forwarded-For" (default) or "Forwarded". Client whose address can't be told, like "unknown" in Forwarded,
+// isn't in any list, so it is blocked only by Allow list
+type IPGuard struct {
+	Allow           []string `mapstructure:"ip_allow"`
+	Deny            []string `mapstructure:"ip_deny"`
+	TrustedProxies  []string `mapstructure:"trusted_proxies"`
+	ForwardedHeader string   `mapstructure:"forwarded_header"`
+
+	lists *ipLists
+}
+
+type ipLists struct {
+	allow   []netip.Prefix
+	deny    []netip.Prefix
+	trusted []netip.Prefix
+}
+
+func (guard *IPGuard) ShouldBlock(req *http.Request) bool {
+	lists := guard.lists
+	if lists == nil {
+		// guards built without decoder parse their lists on every request, entries which can't be parsed are skipped
+		lists = &ipLists{
+			allow:   parseValidPrefixes(guard.Allow),
+			deny:    parseValidPrefixes(guard.Deny),
+			trusted: parseValidPrefixes(guard.TrustedProxies),
+		}
+	}
+
+	client, ok := clientAddr(req, lists.trusted, guard.ForwardedHeader)
+
+	if ok && containsAddr(lists.deny, client) {
+		return true
+	}
+
+	return len(guard.Allow) > 0 && (!ok || !containsAddr(lists.allow, client))
+}
+
+func (guard *IPGuard) IsValid() bool {
+	return len(guard.Allow) > 0 || len(guard.Deny) > 0
+}
+
+func (guard *IPGuard) compile() error {
+	err := validateForwardedHeader(guard.ForwardedHeader)
+	if err != nil {
+		return err
+	}
+
+	lists := &ipLists{}
+
+	lists.allow, err = parsePrefixes(guard.Allow)
+	if err != nil {
+		return err
+	}
+
+	lists.deny, err = parsePrefixes(guard.Deny)
+	if err != nil {
+		return err
+	}
+
+	lists.trusted, err = parsePrefixes(guard.TrustedProxies)
+	if err != nil {
+		return err
+	}
+
+	guard.lists = lists
+
+	return nil
+}
+
+func validateForwardedHeader(forwardedHeader string) error {
+	switch forwardedHeader {
+	case "", headerXForwardedFor, headerForwarded:
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnknownForwardedHeader, forwardedHeader)
+}
+
+// clientAddr walks the chain of proxies from the closest one and stops at first address which isn't trusted proxy
+func clientAddr(req *http.Request, trusted []netip.Prefix, forwardedHeader string) (netip.Addr, bool) {
+	client, ok := parseAddr(req.RemoteAddr)
+	if !ok || !containsAddr(trusted, client) {
+		return client, ok
+	}
+
+	chain := forwardedChain(req, forwardedHeader)
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		client, ok = parseAddr(chain[i])
+		if !ok || !containsAddr(trusted, client) {
+			return client, ok
+		}
+	}
+
+	// every hop is trusted proxy, so the first one is the client
+	return client, ok
+}
+
+func forwardedChain(req *http.Request, forwardedHeader string) []string {
+	if forwardedHeader == headerForwarded {
+		return forwardedFor(req.Header.Values(headerForwarded))
+	}
+
+	var chain []string
+
+	for _, value := range req.Header.Values(headerXForwardedFor) {
+		for _, hop := range strings.Split(value, ",") {
+			chain = append(chain, strings.TrimSpace(hop))
+		}
+	}
+
+	return chain
+}
+
+// forwardedFor returns "for" parameters of Forwarded header elements, like 192.0.2.60 from "for=192.0.2.60;proto=http"
+func forwardedFor(values []string) []string {
+	var chain []string
+
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				name, hop, _ := strings.Cut(strings.TrimSpace(pair), "=")
+				if strings.EqualFold(name, "for") {
+					chain = append(chain, strings.Trim(hop, `"`))
+				}
+			}
+		}
+	}
+
+	return chain
+}
+
+// parseAddr accepts address with or without port, like "10.0.0.1:4711" or "[2001:db8::1]:4711"
+func parseAddr(hostport string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = strings.Trim(hostport, "[]")
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr.WithZone("").Unmap(), true
+}
+
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+
+	for _, value := range values {
+		prefix, err := parsePrefix(value)
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes, nil
+}
+
+func parseValidPrefixes(values []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(values))
+
+	for _, value := range values {
+		prefix, err := parsePrefix(value)
+		if err == nil {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	return prefixes
+}
+
+// parsePrefix parses cidr range, single address becomes range holding only it
+func parsePrefix(value string) (netip.Prefix, error) {
+	if !strings.Contains(value, "/") {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("%w: %s", ErrInvalidCIDR, value)
+		}
+
+		addr = addr.Unmap()
+
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%w: %s", ErrInvalidCIDR, value)
+	}
+
+	return prefix.Masked(), nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,179 @@
+package block_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/vjerci/reverse-proxy/internal/block"
+)
+
+func TestIPGuard(t *testing.T) {
+	decoder := &block.InterfaceGuardDecoder{}
+
+	denyRule := map[string]interface{}{
+		"ip_deny":         []interface{}{"203.0.113.7", "198.51.100.0/24", "2001:db8:bad::/48"},
+		"trusted_proxies": []interface{}{"10.0.0.0/8", "fd00::/8"},
+	}
+
+	allowRule := map[string]interface{}{
+		"ip_allow":         []interface{}{"192.0.2.0/24"},
+		"trusted_proxies":  []interface{}{"10.0.0.1"},
+		"forwarded_header": "Forwarded",
+	}
+
+	testCases := []struct {
+		testName   string
+		rule       map[string]interface{}
+		remoteAddr string
+		headers    map[string]string
+		block      bool
+	}{
+		{
+			testName:   "denied_remote_addr",
+			rule:       denyRule,
+			remoteAddr: "198.51.100.20:4711",
+			block:      true,
+		},
+		{
+			testName:   "passing_remote_addr",
+			rule:       denyRule,
+			remoteAddr: "192.0.2.1:4711",
+			block:      false,
+		},
+		{
+			testName:   "denied_ipv6",
+			rule:       denyRule,
+			remoteAddr: "[2001:db8:bad::1]:4711",
+			block:      true,
+		},
+		{
+			testName:   "denied_ipv4_mapped",
+			rule:       denyRule,
+			remoteAddr: "[::ffff:203.0.113.7]:4711",
+			block:      true,
+		},
+		{
+			testName:   "denied_client_behind_trusted_proxies",
+			rule:       denyRule,
+			remoteAddr: "10.0.0.1:4711",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7, 10.0.0.2"},
+			block:      true,
+		},
+		{
+			testName:   "spoofed_chain_from_untrusted_remote",
+			rule:       denyRule,
+			remoteAddr: "192.0.2.1:4711",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7"},
+			block:      false,
+		},
+		{
+			testName:   "spoofed_hop_before_untrusted_client",
+			rule:       denyRule,
+			remoteAddr: "10.0.0.1:4711",
+			headers:    map[string]string{"X-Forwarded-For": "203.0.113.7, 192.0.2.1"},
+			block:      false,
+		},
+		{
+			testName:   "allowed_client",
+			rule:       allowRule,
+			remoteAddr: "10.0.0.1:4711",
+			headers:    map[string]string{"Forwarded": `for=192.0.2.60;proto=https, for="10.0.0.1"`},
+			block:      false,
+		},
+		{
+			testName:   "not_allowed_client",
+			rule:       allowRule,
+			remoteAddr: "10.0.0.1:4711",
+			headers:    map[string]string{"Forwarded": `for="[2001:db8::1]:4711"`},
+			block:      true,
+		},
+		{
+			testName:   "unknown_client",
+			rule:       allowRule,
+			remoteAddr: "10.0.0.1:4711",
+			headers:    map[string]string{"Forwarded": "for=unknown"},
+			block:      true,
+		},
+		{
+			testName:   "allow_list_ignores_untrusted_header",
+			rule:       allowRule,
+			remoteAddr: "198.51.100.1:4711",
+			headers:    map[string]string{"Forwarded": "for=192.0.2.60"},
+			block:      true,
+		},
+	}
+
+	for _, test := range testCases {
+		guard, err := decoder.Decode(test.rule)
+		if err != nil {
+			t.Fatalf("for test %s failed to decode guard %s", test.testName, err)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "http://domain.com/admin", strings.NewReader(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.RemoteAddr = test.remoteAddr
+
+		for header, value := range test.headers {
+			req.Header.Set(header, value)
+		}
+
+		if guard.ShouldBlock(req) != test.block {
+			t.Fatalf("%s test case failed, expected outcome %t", test.testName, test.block)
+		}
+	}
+}
+
+func TestIPGuardWithoutDecoder(t *testing.T) {
+	guard := &block.IPGuard{
+		Deny: []string{"203.0.113.0/24"},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://domain.com/", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.RemoteAddr = "203.0.113.7:4711"
+
+	if !guard.ShouldBlock(req) {
+		t.Fatalf("expected guard built without decoder to block denied address")
+	}
+}
+
+func TestIPGuardDecodeError(t *testing.T) {
+	decoder := &block.InterfaceGuardDecoder{}
+
+	testCases := []struct {
+		testName      string
+		rule          map[string]interface{}
+		expectedError error
+	}{
+		{
+			testName:      "invalid_cidr",
+			rule:          map[string]interface{}{"ip_deny": []interface{}{"10.0.0.0/33"}},
+			expectedError: block.ErrInvalidCIDR,
+		},
+		{
+			testName:      "invalid_trusted_proxy",
+			rule:          map[string]interface{}{"ip_allow": []interface{}{"10.0.0.1"}, "trusted_proxies": []interface{}{"proxy"}},
+			expectedError: block.ErrInvalidCIDR,
+		},
+		{
+			testName:      "unknown_forwarded_header",
+			rule:          map[string]interface{}{"ip_allow": []interface{}{"10.0.0.1"}, "forwarded_header": "X-Real-IP"},
+			expectedError: block.ErrUnknownForwardedHeader,
+		},
+	}
+
+	for _, test := range testCases {
+		_, err := decoder.Decode(test.rule)
+		if !errors.Is(err, block.ErrDecodeGuard) || !errors.Is(err, test.expectedError) {
+			t.Fatalf("for test %s expected %s got %s instead", test.testName, test.expectedError, err)
+		}
+	}
+}
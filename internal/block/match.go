@@ -0,0 +1,154 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrUnknownMatchMode = errors.New("unknown match mode")
+var ErrInvalidMatchPattern = errors.New("invalid match pattern")
+
+// MatchMode tells how guard compares its value with value from request
+type MatchMode string
+
+const MatchExact = MatchMode("exact")
+const MatchPrefix = MatchMode("prefix")
+const MatchSuffix = MatchMode("suffix")
+const MatchContains = MatchMode("contains")
+const MatchGlob = MatchMode("glob")
+const MatchRegex = MatchMode("regex")
+
+// MatchCaseInsensitive is exact match ignoring case, other modes ignore case with IgnoreCase
+const MatchCaseInsensitive = MatchMode("case_insensitive")
+
+// MatchPresent matches any value, so header or query param guard blocks if request carries it at all
+const MatchPresent = MatchMode("present")
+
+// Matcher is embedded into guards matching request values, empty Mode falls back to default mode of the guard.
+// Glob "*" matches any run of characters and "?" any single character, except in paths where they stop at "/" and "**" crosses it
+type Matcher struct {
+	Mode       MatchMode `mapstructure:"match"`
+	IgnoreCase bool      `mapstructure:"ignore_case"`
+
+	pattern *regexp.Regexp
+}
+
+// compile validates match mode and prepares regex and glob patterns, it is called by decoder.
+// guards built without decoder compile their pattern on every match
+func (matcher *Matcher) compile(pattern string, defaultMode MatchMode, separator string) error {
+	mode := matcher.mode(defaultMode)
+
+	switch mode {
+	case MatchExact, MatchPrefix, MatchSuffix, MatchContains, MatchCaseInsensitive, MatchPresent:
+		return nil
+	case MatchGlob, MatchRegex:
+		compiled, err := matcher.regexp(pattern, mode, separator)
+		if err != nil {
+			return err
+		}
+
+		matcher.pattern = compiled
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnknownMatchMode, mode)
+}
+
+func (matcher *Matcher) mode(defaultMode MatchMode) MatchMode {
+	if matcher.Mode == "" {
+		return defaultMode
+	}
+
+	return matcher.Mode
+}
+
+func (matcher *Matcher) regexp(pattern string, mode MatchMode, separator string) (*regexp.Regexp, error) {
+	if mode == MatchGlob {
+		pattern = globToRegexp(pattern, separator)
+	}
+
+	if matcher.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidMatchPattern, err)
+	}
+
+	return compiled, nil
+}
+
+func (matcher *Matcher) matches(pattern string, value string, defaultMode MatchMode, separator string) bool {
+	mode := matcher.mode(defaultMode)
+
+	if mode == MatchPresent {
+		return true
+	}
+
+	if mode == MatchGlob || mode == MatchRegex {
+		compiled := matcher.pattern
+		if compiled == nil {
+			var err error
+
+			compiled, err = matcher.regexp(pattern, mode, separator)
+			if err != nil {
+				return false
+			}
+		}
+
+		return compiled.MatchString(value)
+	}
+
+	if matcher.IgnoreCase || mode == MatchCaseInsensitive {
+		pattern = strings.ToLower(pattern)
+		value = strings.ToLower(value)
+	}
+
+	switch mode {
+	case MatchPrefix:
+		return strings.HasPrefix(value, pattern)
+	case MatchSuffix:
+		return strings.HasSuffix(value, pattern)
+	case MatchContains:
+		return strings.Contains(value, pattern)
+	}
+
+	return value == pattern
+}
+
+// globToRegexp turns glob into anchored regex, with empty separator "*" and "?" match any character
+func globToRegexp(glob string, separator string) string {
+	anyRun := ".*"
+	anyChar := "."
+
+	if separator != "" {
+		anyRun = "[^" + regexp.QuoteMeta(separator) + "]*"
+		anyChar = "[^" + regexp.QuoteMeta(separator) + "]"
+	}
+
+	var builder strings.Builder
+
+	builder.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			builder.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			builder.WriteString(anyRun)
+		case glob[i] == '?':
+			builder.WriteString(anyChar)
+		default:
+			builder.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+
+	builder.WriteString("$")
+
+	return builder.String()
+}
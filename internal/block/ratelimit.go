@@ -0,0 +1,316 @@
+package block
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+var ErrInvalidRateLimit = errors.New("invalid rate limit")
+
+const rateLimitKeyIP = "ip"
+const rateLimitKeyHeader = "header"
+const rateLimitKeyPath = "path"
+
+const rateLimitTokenBucket = "token_bucket"
+const rateLimitSlidingWindow = "sliding_window"
+
+const defaultRateLimitPer = time.Second
+const defaultRateLimitMaxKeys = 10000
+
+// RateLimitGuard blocks requests over Rate requests Per duration, requests are counted separately for each key.
+// Key is client address ("ip"), value of KeyHeader ("header"), like api key, or request path ("path").
+// Requests without KeyHeader share a single limit. Client address is resolved like in IPGuard.
+// Algorithm "token_bucket" (default) lets Burst requests through at once and refills at Rate, "sliding_window" lets
+// at most Rate requests through in any window of Per duration. Blocked requests report when client can retry to Verdict.
+// Only MaxKeys most recently seen keys are tracked, keys idle long enough to have their limit fully restored are forgotten
+type RateLimitGuard struct {
+	Key             string   `mapstructure:"rate_limit"`
+	KeyHeader       string   `mapstructure:"key_header"`
+	Algorithm       string   `mapstructure:"algorithm"`
+	Rate            float64  `mapstructure:"rate"`
+	Per             string   `mapstructure:"per"`
+	Burst           int      `mapstructure:"burst"`
+	MaxKeys         int      `mapstructure:"max_keys"`
+	TrustedProxies  []string `mapstructure:"trusted_proxies"`
+	ForwardedHeader string   `mapstructure:"forwarded_header"`
+
+	once    sync.Once
+	limiter *rateLimiter
+	trusted []netip.Prefix
+}
+
+func (guard *RateLimitGuard) ShouldBlock(req *http.Request) bool {
+	// guards built without decoder are set up on first request, invalid ones never block
+	guard.once.Do(func() {
+		if guard.limiter == nil {
+			guard.compile()
+		}
+	})
+
+	if guard.limiter == nil {
+		return false
+	}
+
+	retryAfter, allowed := guard.limiter.take(guard.key(req), time.Now())
+	if allowed {
+		return false
+	}
+
+	reportRetryAfter(req, retryAfter)
+
+	return true
+}
+
+func (guard *RateLimitGuard) IsValid() bool {
+	return guard.Key != ""
+}
+
+func (guard *RateLimitGuard) compile() error {
+	switch guard.Key {
+	case rateLimitKeyIP, rateLimitKeyPath:
+	case rateLimitKeyHeader:
+		if guard.KeyHeader == "" {
+			return fmt.Errorf("%w: key header is required for header key", ErrInvalidRateLimit)
+		}
+	default:
+		return fmt.Errorf("%w: unknown key %s", ErrInvalidRateLimit, guard.Key)
+	}
+
+	if guard.Rate <= 0 {
+		return fmt.Errorf("%w: rate must be positive", ErrInvalidRateLimit)
+	}
+
+	per := defaultRateLimitPer
+	if guard.Per != "" {
+		var err error
+
+		per, err = time.ParseDuration(guard.Per)
+		if err != nil || per <= 0 {
+			return fmt.Errorf("%w: invalid per duration %s", ErrInvalidRateLimit, guard.Per)
+		}
+	}
+
+	burst := float64(guard.Burst)
+	if burst <= 0 {
+		burst = math.Max(math.Ceil(guard.Rate), 1)
+	}
+
+	maxKeys := guard.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = defaultRateLimitMaxKeys
+	}
+
+	err := validateForwardedHeader(guard.ForwardedHeader)
+	if err != nil {
+		return err
+	}
+
+	trusted, err := parsePrefixes(guard.TrustedProxies)
+	if err != nil {
+		return err
+	}
+
+	var algorithm rateAlgorithm
+
+	switch guard.Algorithm {
+	case "", rateLimitTokenBucket:
+		algorithm = &tokenBucket{
+			burst:    burst,
+			interval: time.Duration(float64(per) / guard.Rate),
+		}
+	case rateLimitSlidingWindow:
+		if guard.Rate < 1 {
+			return fmt.Errorf("%w: sliding window rate must be at least 1", ErrInvalidRateLimit)
+		}
+
+		algorithm = &slidingWindow{
+			limit:  guard.Rate,
+			window: per,
+		}
+	default:
+		return fmt.Errorf("%w: unknown algorithm %s", ErrInvalidRateLimit, guard.Algorithm)
+	}
+
+	guard.trusted = trusted
+	guard.limiter = newRateLimiter(algorithm, maxKeys)
+
+	return nil
+}
+
+func (guard *RateLimitGuard) key(req *http.Request) string {
+	switch guard.Key {
+	case rateLimitKeyHeader:
+		return req.Header.Get(guard.KeyHeader)
+	case rateLimitKeyPath:
+		return req.URL.Path
+	}
+
+	client, ok := clientAddr(req, guard.trusted, guard.ForwardedHeader)
+	if !ok {
+		return ""
+	}
+
+	return client.String()
+}
+
+// rateAlgorithm decides whether request fits into limit of its key, state of each key is kept in rateState
+type rateAlgorithm interface {
+	take(state *rateState, now time.Time) (retryAfter time.Duration, allowed bool)
+	// idle is how long key has to be unused for its limit to be fully restored
+	idle() time.Duration
+}
+
+type rateState struct {
+	key      string
+	lastSeen time.Time
+
+	// token bucket keeps the time at which bucket would be full again
+	fullAt time.Time
+
+	// sliding window keeps counts of current and previous window
+	windowStart time.Time
+	previous    float64
+	current     float64
+}
+
+// tokenBucket holds up to burst tokens and gets a new one every interval, request takes one token
+type tokenBucket struct {
+	burst    float64
+	interval time.Duration
+}
+
+func (bucket *tokenBucket) take(state *rateState, now time.Time) (time.Duration, bool) {
+	fullAt := state.fullAt
+	if fullAt.Before(now) {
+		fullAt = now
+	}
+
+	// bucket is fullAt - now worth of tokens below full, taking a token moves fullAt by one interval
+	missing := float64(fullAt.Add(bucket.interval).Sub(now)) / float64(bucket.interval)
+	if missing > bucket.burst {
+		return fullAt.Add(bucket.interval).Sub(now) - time.Duration(bucket.burst*float64(bucket.interval)), false
+	}
+
+	state.fullAt = fullAt.Add(bucket.interval)
+
+	return 0, true
+}
+
+func (bucket *tokenBucket) idle() time.Duration {
+	return time.Duration(bucket.burst * float64(bucket.interval))
+}
+
+// slidingWindow estimates requests in the last window from counts of current and previous fixed window,
+// weighting previous one by the part of it which is still inside the last window
+type slidingWindow struct {
+	limit  float64
+	window time.Duration
+}
+
+func (window *slidingWindow) take(state *rateState, now time.Time) (time.Duration, bool) {
+	start := now.Truncate(window.window)
+
+	switch {
+	case start.Sub(state.windowStart) == window.window:
+		state.previous = state.current
+		state.current = 0
+	case !start.Equal(state.windowStart):
+		state.previous = 0
+		state.current = 0
+	}
+
+	state.windowStart = start
+
+	elapsed := float64(now.Sub(start)) / float64(window.window)
+
+	if state.previous*(1-elapsed)+state.current+1 <= window.limit {
+		state.current++
+		return 0, true
+	}
+
+	return window.retryAfter(state, elapsed), false
+}
+
+// retryAfter is when weighted count of the last window leaves room for one more request
+func (window *slidingWindow) retryAfter(state *rateState, elapsed float64) time.Duration {
+	windowLength := float64(window.window)
+
+	if state.current+1 <= window.limit && state.previous > 0 {
+		// room is made within current window as previous one slides out
+		at := 1 - (window.limit-state.current-1)/state.previous
+
+		return time.Duration((at - elapsed) * windowLength)
+	}
+
+	// room is made in the next window as current one, which becomes the previous, slides out
+	at := 1 - (window.limit-1)/state.current
+
+	return time.Duration((1 - elapsed + at) * windowLength)
+}
+
+func (window *slidingWindow) idle() time.Duration {
+	return 2 * window.window
+}
+
+// rateLimiter keeps state of keys in least recently used order, so keys over maxKeys and idle keys are evicted from the back
+type rateLimiter struct {
+	mu        sync.Mutex
+	algorithm rateAlgorithm
+	maxKeys   int
+	states    map[string]*list.Element
+	order     *list.List
+}
+
+func newRateLimiter(algorithm rateAlgorithm, maxKeys int) *rateLimiter {
+	return &rateLimiter{
+		algorithm: algorithm,
+		maxKeys:   maxKeys,
+		states:    make(map[string]*list.Element),
+		order:     list.New(),
+	}
+}
+
+func (limiter *rateLimiter) take(key string, now time.Time) (time.Duration, bool) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	limiter.evictIdle(now)
+
+	element, ok := limiter.states[key]
+	if ok {
+		limiter.order.MoveToFront(element)
+	} else {
+		element = limiter.order.PushFront(&rateState{key: key})
+		limiter.states[key] = element
+
+		if limiter.order.Len() > limiter.maxKeys {
+			limiter.evict(limiter.order.Back())
+		}
+	}
+
+	state := element.Value.(*rateState)
+	state.lastSeen = now
+
+	return limiter.algorithm.take(state, now)
+}
+
+func (limiter *rateLimiter) evictIdle(now time.Time) {
+	for back := limiter.order.Back(); back != nil; back = limiter.order.Back() {
+		if now.Sub(back.Value.(*rateState).lastSeen) < limiter.algorithm.idle() {
+			return
+		}
+
+		limiter.evict(back)
+	}
+}
+
+func (limiter *rateLimiter) evict(element *list.Element) {
+	limiter.order.Remove(element)
+	delete(limiter.states, element.Value.(*rateState).key)
+}
@@ -0,0 +1,86 @@
+package block
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	limiter := newRateLimiter(&tokenBucket{burst: 2, interval: time.Second}, 10)
+	start := time.Unix(1000, 0)
+
+	testCases := []struct {
+		at         time.Duration
+		allowed    bool
+		retryAfter time.Duration
+	}{
+		{at: 0, allowed: true},
+		{at: 0, allowed: true},
+		{at: 0, allowed: false, retryAfter: time.Second},
+		{at: 500 * time.Millisecond, allowed: false, retryAfter: 500 * time.Millisecond},
+		{at: time.Second, allowed: true},
+		{at: time.Second, allowed: false, retryAfter: time.Second},
+		{at: 10 * time.Second, allowed: true},
+		{at: 10 * time.Second, allowed: true},
+	}
+
+	for i, test := range testCases {
+		retryAfter, allowed := limiter.take("client", start.Add(test.at))
+		if allowed != test.allowed || retryAfter != test.retryAfter {
+			t.Fatalf("request %d expected allowed %t retry after %s, got %t %s", i, test.allowed, test.retryAfter, allowed, retryAfter)
+		}
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	limiter := newRateLimiter(&slidingWindow{limit: 2, window: 10 * time.Second}, 10)
+	start := time.Unix(1000, 0)
+
+	testCases := []struct {
+		at         time.Duration
+		allowed    bool
+		retryAfter time.Duration
+	}{
+		{at: 0, allowed: true},
+		{at: 1 * time.Second, allowed: true},
+		{at: 2 * time.Second, allowed: false, retryAfter: 13 * time.Second},
+		// previous window counts 2 weighted by 0.5, so one more request fits
+		{at: 15 * time.Second, allowed: true},
+		{at: 15 * time.Second, allowed: false, retryAfter: 5 * time.Second},
+		{at: 20 * time.Second, allowed: true},
+		// windows without requests in between reset counts
+		{at: 60 * time.Second, allowed: true},
+		{at: 60 * time.Second, allowed: true},
+	}
+
+	for i, test := range testCases {
+		retryAfter, allowed := limiter.take("client", start.Add(test.at))
+		if allowed != test.allowed || retryAfter != test.retryAfter {
+			t.Fatalf("request %d expected allowed %t retry after %s, got %t %s", i, test.allowed, test.retryAfter, allowed, retryAfter)
+		}
+	}
+}
+
+func TestRateLimiterEviction(t *testing.T) {
+	limiter := newRateLimiter(&tokenBucket{burst: 1, interval: time.Minute}, 2)
+	start := time.Unix(1000, 0)
+
+	limiter.take("a", start)
+	limiter.take("b", start)
+	limiter.take("a", start)
+	limiter.take("c", start)
+
+	if _, ok := limiter.states["b"]; ok {
+		t.Fatalf("expected least recently used key to be evicted over max keys")
+	}
+
+	if len(limiter.states) != 2 {
+		t.Fatalf("expected 2 tracked keys, got %d", len(limiter.states))
+	}
+
+	limiter.take("d", start.Add(time.Minute))
+
+	if len(limiter.states) != 1 {
+		t.Fatalf("expected idle keys to be evicted, got %d tracked keys", len(limiter.states))
+	}
+}
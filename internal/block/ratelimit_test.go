@@ -0,0 +1,160 @@
+package block_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/vjerci/reverse-proxy/internal/block"
+)
+
+func TestRateLimitGuard(t *testing.T) {
+	decoder := &block.InterfaceGuardDecoder{}
+
+	testCases := []struct {
+		testName string
+		rule     map[string]interface{}
+		requests []map[string]string
+		blocks   []bool
+	}{
+		{
+			testName: "ip_token_bucket",
+			rule:     map[string]interface{}{"rate_limit": "ip", "rate": 1, "per": "1h", "burst": 2},
+			requests: []map[string]string{{}, {}, {}},
+			blocks:   []bool{false, false, true},
+		},
+		{
+			testName: "header_keys_are_limited_separately",
+			rule:     map[string]interface{}{"rate_limit": "header", "key_header": "X-Api-Key", "rate": 1, "per": "1h"},
+			requests: []map[string]string{{"X-Api-Key": "a"}, {"X-Api-Key": "b"}, {"X-Api-Key": "a"}},
+			blocks:   []bool{false, false, true},
+		},
+		{
+			testName: "sliding_window",
+			rule:     map[string]interface{}{"rate_limit": "path", "algorithm": "sliding_window", "rate": 2, "per": "1h"},
+			requests: []map[string]string{{}, {}, {}},
+			blocks:   []bool{false, false, true},
+		},
+		{
+			testName: "client_behind_trusted_proxy",
+			rule:     map[string]interface{}{"rate_limit": "ip", "rate": 1, "per": "1h", "trusted_proxies": []interface{}{"10.0.0.0/8"}},
+			requests: []map[string]string{{"X-Forwarded-For": "192.0.2.1"}, {"X-Forwarded-For": "192.0.2.2"}, {"X-Forwarded-For": "192.0.2.1"}},
+			blocks:   []bool{false, false, true},
+		},
+	}
+
+	for _, test := range testCases {
+		guard, err := decoder.Decode(test.rule)
+		if err != nil {
+			t.Fatalf("for test %s failed to decode guard %s", test.testName, err)
+		}
+
+		for i, headers := range test.requests {
+			req, err := http.NewRequest(http.MethodGet, "http://domain.com/api", strings.NewReader(""))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.RemoteAddr = "10.0.0.1:4711"
+
+			for header, value := range headers {
+				req.Header.Set(header, value)
+			}
+
+			req, verdict := block.WithVerdict(req)
+
+			if guard.ShouldBlock(req) != test.blocks[i] {
+				t.Fatalf("%s test case failed on request %d, expected outcome %t", test.testName, i, test.blocks[i])
+			}
+
+			if (verdict.RetryAfter > 0) != test.blocks[i] {
+				t.Fatalf("%s test case failed on request %d, unexpected retry after %s", test.testName, i, verdict.RetryAfter)
+			}
+		}
+	}
+}
+
+func TestRateLimitVerdictCheckpoint(t *testing.T) {
+	guard, err := block.GuardsFromInterface(map[string]interface{}{
+		"all": []interface{}{
+			map[string]interface{}{"rate_limit": "ip", "rate": 1, "per": "1h", "burst": 1},
+			map[string]interface{}{"method": "POST"},
+		},
+	}, &block.InterfaceGuardDecoder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest(http.MethodGet, "http://domain.com/api", strings.NewReader(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.RemoteAddr = "192.0.2.1:4711"
+
+		req, verdict := block.WithVerdict(req)
+
+		if guard.ShouldBlock(req) {
+			t.Fatalf("expected request %d not to be blocked", i)
+		}
+
+		if verdict.RetryAfter != 0 {
+			t.Fatalf("expected request %d not blocked to have no retry after, got %s", i, verdict.RetryAfter)
+		}
+	}
+}
+
+func TestRateLimitGuardDecodeError(t *testing.T) {
+	decoder := &block.InterfaceGuardDecoder{}
+
+	testCases := []struct {
+		testName      string
+		rule          map[string]interface{}
+		expectedError error
+	}{
+		{
+			testName:      "unknown_key",
+			rule:          map[string]interface{}{"rate_limit": "cookie", "rate": 1},
+			expectedError: block.ErrInvalidRateLimit,
+		},
+		{
+			testName:      "missing_key_header",
+			rule:          map[string]interface{}{"rate_limit": "header", "rate": 1},
+			expectedError: block.ErrInvalidRateLimit,
+		},
+		{
+			testName:      "missing_rate",
+			rule:          map[string]interface{}{"rate_limit": "ip"},
+			expectedError: block.ErrInvalidRateLimit,
+		},
+		{
+			testName:      "invalid_per",
+			rule:          map[string]interface{}{"rate_limit": "ip", "rate": 1, "per": "minute"},
+			expectedError: block.ErrInvalidRateLimit,
+		},
+		{
+			testName:      "unknown_algorithm",
+			rule:          map[string]interface{}{"rate_limit": "ip", "rate": 1, "algorithm": "leaky_bucket"},
+			expectedError: block.ErrInvalidRateLimit,
+		},
+		{
+			testName:      "sliding_window_fraction_rate",
+			rule:          map[string]interface{}{"rate_limit": "ip", "rate": 0.5, "algorithm": "sliding_window"},
+			expectedError: block.ErrInvalidRateLimit,
+		},
+		{
+			testName:      "invalid_trusted_proxy",
+			rule:          map[string]interface{}{"rate_limit": "ip", "rate": 1, "trusted_proxies": []interface{}{"proxy"}},
+			expectedError: block.ErrInvalidCIDR,
+		},
+	}
+
+	for _, test := range testCases {
+		_, err := decoder.Decode(test.rule)
+		if !errors.Is(err, block.ErrDecodeGuard) || !errors.Is(err, test.expectedError) {
+			t.Fatalf("for test %s expected %s got %s instead", test.testName, test.expectedError, err)
+		}
+	}
+}
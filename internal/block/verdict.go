@@ -0,0 +1,44 @@
+package block
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type verdictKey struct{}
+
+// Verdict carries details of why request was blocked, guards fill it in while request is checked.
+// RetryAfter is set when request was blocked by rate limit and tells when client can try again
+type Verdict struct {
+	RetryAfter time.Duration
+}
+
+// WithVerdict returns request carrying verdict which guards checking it report to
+func WithVerdict(req *http.Request) (*http.Request, *Verdict) {
+	verdict := &Verdict{}
+
+	return req.WithContext(context.WithValue(req.Context(), verdictKey{}, verdict)), verdict
+}
+
+func reportRetryAfter(req *http.Request, retryAfter time.Duration) {
+	verdict, ok := req.Context().Value(verdictKey{}).(*Verdict)
+	if ok && retryAfter > verdict.RetryAfter {
+		verdict.RetryAfter = retryAfter
+	}
+}
+
+// verdictCheckpoint returns function which drops details reported after checkpoint, composite guards call it
+// when guards below them block but they don't, so verdict describes only blocks which block the request
+func verdictCheckpoint(req *http.Request) func() {
+	verdict, ok := req.Context().Value(verdictKey{}).(*Verdict)
+	if !ok {
+		return func() {}
+	}
+
+	checkpoint := *verdict
+
+	return func() {
+		*verdict = checkpoint
+	}
+}
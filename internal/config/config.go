@@ -6,16 +6,159 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 )
 
 var ErrConfigNotSet = errors.New("CONFIG_FILE env var not set")
 var ErrConfigRead = errors.New("couldn't read config file")
 var ErrConfigJSON = errors.New("couldn't decode json of config file")
+var ErrConfigDuration = errors.New("couldn't parse duration")
 
 type ConfigData struct {
-	ForwardHost   string          `json:"forward_host"`
-	ForwardScheme string          `json:"forward_scheme"`
-	Block         [][]interface{} `json:"block"`
+	ForwardHost   string                    `json:"forward_host"`
+	ForwardScheme string                    `json:"forward_scheme"`
+	Timeout       Duration                  `json:"timeout"`
+	Upstreams     map[string]UpstreamConfig `json:"upstreams"`
+	Routes        []RouteConfig             `json:"routes"`
+	Buffering     BufferingConfig           `json:"buffering"`
+	Masking       MaskingConfig             `json:"masking"`
+	Block         interface{}               `json:"block"`
+}
+
+// sizes are in bytes, request bodies are logged unless log_request_body is set to false
+type BufferingConfig struct {
+	MaxRequestBody     int64 `json:"max_request_body"`
+	MaxResponseBody    int64 `json:"max_response_body"`
+	LogRequestBody     *bool `json:"log_request_body"`
+	MaxStreamedBodyLog int   `json:"max_streamed_body_log"`
+}
+
+// directions are "none", "request", "response" or "both", methods override direction for requests with given method.
+// rules replace default PII rules when set, exclude json paths are never masked by any rule.
+// keys are used by tokenizing masks and are indexed by key id. exact numbers pass numbers to masks without float64 rounding.
+// drop files removes file parts of multipart forms.
+// content types map extra media types to syntax of inspector masking them: "json", "xml", "form", "multipart", "ndjson" or "event_stream".
+// unknown is "pass" (default), "block" or "log" and decides what happens to bodies which should be masked but have no inspector
+type MaskingConfig struct {
+	Direction    string                      `json:"direction"`
+	Methods      map[string]string           `json:"methods"`
+	Unknown      string                      `json:"unknown"`
+	ContentTypes map[string]string           `json:"content_types"`
+	Rules        []MaskingRuleConfig         `json:"rules"`
+	Exclude      []string                    `json:"exclude"`
+	Keys         map[string]MaskingKeyConfig `json:"keys"`
+	ExactNumbers bool                        `json:"exact_numbers"`
+	DropFiles    bool                        `json:"drop_files"`
+}
+
+// base64 encoded key is read from env var or file, so secrets aren't stored in config
+type MaskingKeyConfig struct {
+	Env  string `json:"env"`
+	File string `json:"file"`
+}
+
+// field is a regex matched against field name, values are names of value patterns matched against field value,
+// paths and exclude are json paths like "$.users[*].email" of fields rule masks or skips. empty types match fields of any type
+type MaskingRuleConfig struct {
+	Field   string     `json:"field"`
+	Values  []string   `json:"values"`
+	Paths   []string   `json:"paths"`
+	Exclude []string   `json:"exclude"`
+	Types   []string   `json:"types"`
+	Mask    MaskConfig `json:"mask"`
+}
+
+// strategy "replace" (default) replaces values with String, Float64 and Bool, unset ones are replaced with "x", 0 and false.
+// strategies "keep_last", "email", "substitute", "fixed_width" and "format_preserving" mask strings partially or keep their format.
+// strategies "hmac" and "encrypt" replace values with tokens made by key KeyID.
+// strategies "drop", "null", "empty" and "redact" mask objects and arrays as a whole too
+type MaskConfig struct {
+	Strategy string   `json:"strategy"`
+	String   *string  `json:"string"`
+	Float64  *float64 `json:"float64"`
+	Bool     *bool    `json:"bool"`
+	Keep     int      `json:"keep"`
+	Width    int      `json:"width"`
+	Char     string   `json:"char"`
+	KeyID    string   `json:"key_id"`
+	Prefix   string   `json:"prefix"`
+	Length   int      `json:"length"`
+	Marker   string   `json:"marker"`
+}
+
+// host is a shorthand for a single target upstream
+type UpstreamConfig struct {
+	Host               string                    `json:"host"`
+	Scheme             string                    `json:"scheme"`
+	Targets            []TargetConfig            `json:"targets"`
+	Balancer           string                    `json:"balancer"`
+	HashHeader         string                    `json:"hash_header"`
+	HealthCheck        *HealthCheckConfig        `json:"health_check"`
+	PassiveHealthCheck *PassiveHealthCheckConfig `json:"passive_health_check"`
+	Retry              *RetryConfig              `json:"retry"`
+	CircuitBreaker     *CircuitBreakerConfig     `json:"circuit_breaker"`
+}
+
+type TargetConfig struct {
+	Host   string `json:"host"`
+	Weight int    `json:"weight"`
+}
+
+type HealthCheckConfig struct {
+	Path           string   `json:"path"`
+	Interval       Duration `json:"interval"`
+	Timeout        Duration `json:"timeout"`
+	ExpectedStatus int      `json:"expected_status"`
+}
+
+type PassiveHealthCheckConfig struct {
+	MaxFailures int      `json:"max_failures"`
+	Cooldown    Duration `json:"cooldown"`
+}
+
+type RetryConfig struct {
+	MaxAttempts        int      `json:"max_attempts"`
+	InitialBackoff     Duration `json:"initial_backoff"`
+	MaxBackoff         Duration `json:"max_backoff"`
+	RetryOnStatus      []int    `json:"retry_on_status"`
+	RetryNonIdempotent bool     `json:"retry_non_idempotent"`
+}
+
+type CircuitBreakerConfig struct {
+	FailureRatio     float64  `json:"failure_ratio"`
+	MinRequests      int      `json:"min_requests"`
+	Window           Duration `json:"window"`
+	OpenDuration     Duration `json:"open_duration"`
+	HalfOpenRequests int      `json:"half_open_requests"`
+}
+
+// Duration is decoded from strings like "10s" or "500ms"
+type Duration time.Duration
+
+func (duration *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrConfigDuration, err)
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrConfigDuration, err)
+	}
+
+	*duration = Duration(parsed)
+
+	return nil
+}
+
+type RouteConfig struct {
+	Host          string `json:"host"`
+	PathPrefix    string `json:"path_prefix"`
+	StripPrefix   bool   `json:"strip_prefix"`
+	RewritePrefix string `json:"rewrite_prefix"`
+	Upstream      string `json:"upstream"`
+	Mask          string `json:"mask"`
 }
 
 func Load(configFilePath string) (*ConfigData, error) {
@@ -3,6 +3,7 @@ package config_test
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/vjerci/reverse-proxy/internal/config"
@@ -29,6 +30,11 @@ func TestLoadConfigErrors(t *testing.T) {
 			input:         "./testdata/faulty_config.json",
 			expectedError: config.ErrConfigJSON,
 		},
+		{
+			testName:      "faulty_duration",
+			input:         "./testdata/faulty_duration_config.json",
+			expectedError: config.ErrConfigDuration,
+		},
 	}
 
 	for _, test := range testCases {
@@ -51,15 +57,14 @@ func TestLoadConfigSuccess(t *testing.T) {
 		forwardHost   string
 		forwardScheme string
 		input         string
-		block         [][]interface{}
+		block         interface{}
 	}{
 		{
 			forwardHost:   "localhost:8000",
 			forwardScheme: "http",
 			input:         "./testdata/config.json",
-			block: [][]interface{}{
-				{
-
+			block: []interface{}{
+				[]interface{}{
 					map[string]interface{}{
 						"method": "get",
 					},
@@ -84,3 +89,137 @@ func TestLoadConfigSuccess(t *testing.T) {
 		assert.Equal(t, test.block, config.Block, "expected block to be %#v, got %#v instead", test.block, config.Block)
 	}
 }
+
+func TestLoadConfigRoutes(t *testing.T) {
+	configData, err := config.Load("./testdata/routes_config.json")
+
+	assert.Nil(t, err, "expected err to be nil")
+
+	assert.Equal(t, config.Duration(5*time.Second), configData.Timeout, "expected timeout to be loaded")
+
+	assert.Equal(t, "users:8000", configData.Upstreams["users"].Host, "expected users upstream host to be loaded")
+
+	assert.Equal(t, "http", configData.Upstreams["users"].Scheme, "expected users upstream scheme to be loaded")
+
+	orders := configData.Upstreams["orders"]
+
+	assert.Equal(t, "consistent_hash", orders.Balancer, "expected orders upstream balancer to be loaded")
+
+	assert.Equal(t, "X-User-ID", orders.HashHeader, "expected orders upstream hash header to be loaded")
+
+	assert.Equal(t, []config.TargetConfig{
+		{
+			Host:   "orders-1:8000",
+			Weight: 2,
+		},
+		{
+			Host: "orders-2:8000",
+		},
+	}, orders.Targets, "expected orders upstream targets to be loaded")
+
+	assert.Equal(t, &config.HealthCheckConfig{
+		Path:           "/health",
+		Interval:       config.Duration(5 * time.Second),
+		Timeout:        config.Duration(500 * time.Millisecond),
+		ExpectedStatus: 204,
+	}, orders.HealthCheck, "expected orders upstream health check to be loaded")
+
+	assert.Equal(t, &config.PassiveHealthCheckConfig{
+		MaxFailures: 3,
+		Cooldown:    config.Duration(time.Minute),
+	}, orders.PassiveHealthCheck, "expected orders upstream passive health check to be loaded")
+
+	assert.Equal(t, &config.RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: config.Duration(50 * time.Millisecond),
+		MaxBackoff:     config.Duration(time.Second),
+		RetryOnStatus:  []int{502, 503},
+	}, orders.Retry, "expected orders upstream retry to be loaded")
+
+	assert.Equal(t, &config.CircuitBreakerConfig{
+		FailureRatio:     0.5,
+		MinRequests:      20,
+		Window:           config.Duration(10 * time.Second),
+		OpenDuration:     config.Duration(30 * time.Second),
+		HalfOpenRequests: 2,
+	}, orders.CircuitBreaker, "expected orders upstream circuit breaker to be loaded")
+
+	logRequestBody := false
+
+	assert.Equal(t, config.BufferingConfig{
+		MaxRequestBody:     1024,
+		MaxResponseBody:    2048,
+		LogRequestBody:     &logRequestBody,
+		MaxStreamedBodyLog: 512,
+	}, configData.Buffering, "expected buffering to be loaded")
+
+	assert.Len(t, configData.Routes, 1, "expected 1 route to be loaded")
+
+	route := configData.Routes[0]
+
+	assert.Equal(t, "api.domain.com", route.Host, "expected route host to be loaded")
+
+	assert.Equal(t, "/users", route.PathPrefix, "expected route path prefix to be loaded")
+
+	assert.True(t, route.StripPrefix, "expected route strip prefix to be loaded")
+
+	assert.Equal(t, "/v1", route.RewritePrefix, "expected route rewrite prefix to be loaded")
+
+	assert.Equal(t, "users", route.Upstream, "expected route upstream to be loaded")
+
+	assert.Equal(t, "both", route.Mask, "expected route mask to be loaded")
+
+	assert.Equal(t, "none", configData.Masking.Direction, "expected default masking direction to be loaded")
+
+	assert.Equal(t, map[string]string{"GET": "response", "POST": "request"}, configData.Masking.Methods, "expected masking directions of methods to be loaded")
+
+	assert.Equal(t, "log", configData.Masking.Unknown, "expected unknown content policy to be loaded")
+
+	assert.Equal(t, map[string]string{"application/vnd.legacy": "xml", "text/json; profile=audit": "json"}, configData.Masking.ContentTypes, "expected masked content types to be loaded")
+
+	assert.Len(t, configData.Masking.Rules, 5, "expected 5 masking rules to be loaded")
+
+	rule := configData.Masking.Rules[0]
+
+	assert.Equal(t, `\w*email\w*`, rule.Field, "expected masking rule field to be loaded")
+
+	assert.Equal(t, []string{"string"}, rule.Types, "expected masking rule types to be loaded")
+
+	assert.Equal(t, "replace", rule.Mask.Strategy, "expected masking rule strategy to be loaded")
+
+	assert.Equal(t, "redacted", *rule.Mask.String, "expected masking rule string replacement to be loaded")
+
+	assert.Nil(t, configData.Masking.Rules[1].Mask.String, "expected unset replacement to be nil")
+
+	assert.Equal(t, []string{"credit_card", "iban"}, configData.Masking.Rules[1].Values, "expected masking rule value patterns to be loaded")
+
+	assert.Equal(t, []string{"$.users[*].payment"}, configData.Masking.Rules[2].Paths, "expected masking rule paths to be loaded")
+
+	assert.Equal(t, []string{"$..card_type"}, configData.Masking.Rules[2].Exclude, "expected masking rule excluded paths to be loaded")
+
+	assert.Equal(t, []string{"$.product.name"}, configData.Masking.Exclude, "expected excluded paths to be loaded")
+
+	assert.Equal(t, config.MaskConfig{Strategy: "keep_last", Keep: 4, Char: "#"}, configData.Masking.Rules[2].Mask, "expected masking rule strategy options to be loaded")
+
+	assert.Equal(t, config.MaskConfig{Strategy: "hmac", KeyID: "2024", Prefix: "tok_", Length: 16}, configData.Masking.Rules[3].Mask, "expected masking rule token options to be loaded")
+
+	assert.Equal(t, config.MaskConfig{Strategy: "redact", Marker: "[HIDDEN]"}, configData.Masking.Rules[4].Mask, "expected masking rule marker to be loaded")
+
+	assert.Equal(t, []string{"object", "array", "null"}, configData.Masking.Rules[4].Types, "expected masking rule container types to be loaded")
+
+	assert.True(t, configData.Masking.ExactNumbers, "expected exact numbers to be loaded")
+
+	assert.True(t, configData.Masking.DropFiles, "expected drop files to be loaded")
+
+	assert.Equal(t, map[string]interface{}{
+		"all": []interface{}{
+			map[string]interface{}{"method": "DELETE"},
+			map[string]interface{}{"not": map[string]interface{}{"header": "X-Admin", "match": "present"}},
+		},
+	}, configData.Block, "expected nested block rules to be loaded")
+
+	assert.Equal(t, map[string]config.MaskingKeyConfig{
+		"2024": {Env: "MASKING_KEY_2024"},
+		"2023": {File: "/run/secrets/masking_key_2023"},
+	}, configData.Masking.Keys, "expected masking keys to be loaded")
+}
@@ -1,42 +1,70 @@
 package log
 
 import (
+	"bytes"
+	"io"
 	"net/http"
 )
 
+// size of chunks streamed responses are copied and flushed in
+const streamChunkSize = 32 * 1024
+
+// DefaultMaxStreamedBodyLog is how much of streamed response body is logged when limit isn't set,
+// masked responses are streamed too, so it keeps them logged whole unless they are large
+const DefaultMaxStreamedBodyLog = 64 * 1024
+
 type Logger interface {
 	Print(data ...any)
 }
 
 type ResponseWriterFactory interface {
 	New(req *http.Request, reqBody []byte, writer http.ResponseWriter) ResponseWriter
+	// NeedsRequestBody tells whether request body has to be buffered in order to be logged
+	NeedsRequestBody() bool
 }
 
 type ResponseWriter interface {
 	Write(statusCode int, headers map[string][]string, content []byte)
+	// Stream copies content to client as it arrives, flushing after each chunk
+	Stream(statusCode int, headers map[string][]string, content io.Reader)
 }
 
 type ResponseWriterFactoryInstance struct {
 	Logger Logger
+	// LogRequestBody makes request bodies buffered so they can be logged, otherwise they are streamed upstream
+	LogRequestBody bool
+	// MaxStreamedBodyLog is how many bytes of streamed response body end up in the log, DefaultMaxStreamedBodyLog if not set
+	MaxStreamedBodyLog int
 }
 
 func (factory *ResponseWriterFactoryInstance) New(req *http.Request, reqBody []byte, writer http.ResponseWriter) ResponseWriter {
-	return newLoggingResponseWriter(factory.Logger, req, reqBody, writer)
+	maxStreamedBodyLog := factory.MaxStreamedBodyLog
+	if maxStreamedBodyLog <= 0 {
+		maxStreamedBodyLog = DefaultMaxStreamedBodyLog
+	}
+
+	return newLoggingResponseWriter(factory.Logger, maxStreamedBodyLog, req, reqBody, writer)
+}
+
+func (factory *ResponseWriterFactoryInstance) NeedsRequestBody() bool {
+	return factory.LogRequestBody
 }
 
 type ResponseWriterInstance struct {
-	logger  Logger
-	req     *http.Request
-	reqBody []byte
-	writer  http.ResponseWriter
+	logger             Logger
+	maxStreamedBodyLog int
+	req                *http.Request
+	reqBody            []byte
+	writer             http.ResponseWriter
 }
 
-func newLoggingResponseWriter(logger Logger, req *http.Request, reqBody []byte, writer http.ResponseWriter) ResponseWriter {
+func newLoggingResponseWriter(logger Logger, maxStreamedBodyLog int, req *http.Request, reqBody []byte, writer http.ResponseWriter) ResponseWriter {
 	return &ResponseWriterInstance{
-		logger:  logger,
-		req:     req,
-		reqBody: reqBody,
-		writer:  writer,
+		logger:             logger,
+		maxStreamedBodyLog: maxStreamedBodyLog,
+		req:                req,
+		reqBody:            reqBody,
+		writer:             writer,
 	}
 }
 
@@ -46,6 +74,58 @@ func (loggingWriter *ResponseWriterInstance) Write(statusCode int, headers map[s
 		panic(err)
 	}
 
+	loggingWriter.writeHeaders(statusCode, headers)
+	loggingWriter.writer.Write(content)
+}
+
+func (loggingWriter *ResponseWriterInstance) Stream(statusCode int, headers map[string][]string, content io.Reader) {
+	loggingWriter.writeHeaders(statusCode, headers)
+
+	flusher, canFlush := loggingWriter.writer.(http.Flusher)
+	if canFlush {
+		flusher.Flush()
+	}
+
+	captured := &limitedBuffer{
+		limit: loggingWriter.maxStreamedBodyLog,
+	}
+
+	buff := make([]byte, streamChunkSize)
+
+	var streamErr error
+	for {
+		n, err := content.Read(buff)
+		if n > 0 {
+			captured.Write(buff[:n])
+
+			_, writeErr := loggingWriter.writer.Write(buff[:n])
+			if writeErr != nil {
+				streamErr = writeErr
+				break
+			}
+
+			if canFlush {
+				flusher.Flush()
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			streamErr = err
+			break
+		}
+	}
+
+	err := PrintStreamedResponse(loggingWriter.logger, loggingWriter.req, loggingWriter.reqBody, statusCode, headers, captured.Bytes(), captured.truncated, streamErr)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (loggingWriter *ResponseWriterInstance) writeHeaders(statusCode int, headers map[string][]string) {
 	for header, headerValues := range headers {
 		for _, headerValue := range headerValues {
 			loggingWriter.writer.Header().Set(header, headerValue)
@@ -53,5 +133,21 @@ func (loggingWriter *ResponseWriterInstance) Write(statusCode int, headers map[s
 	}
 
 	loggingWriter.writer.WriteHeader(statusCode)
-	loggingWriter.writer.Write(content)
+}
+
+// limitedBuffer keeps only first limit bytes written to it
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (buffer *limitedBuffer) Write(data []byte) (int, error) {
+	room := buffer.limit - buffer.Len()
+	if len(data) > room {
+		buffer.truncated = true
+		data = data[:room]
+	}
+
+	return buffer.Buffer.Write(data)
 }
@@ -19,12 +19,38 @@ type RequestLog struct {
 }
 
 type ResponseLog struct {
-	ResponseHeaders    map[string][]string `json:"resp_headers"`
-	ResponseBody       string              `json:"resp_body"`
-	ResponseStatusCode int                 `json:"resp_status_code"`
+	ResponseHeaders       map[string][]string `json:"resp_headers"`
+	ResponseBody          string              `json:"resp_body"`
+	ResponseStatusCode    int                 `json:"resp_status_code"`
+	ResponseBodyTruncated bool                `json:"resp_body_truncated,omitempty"`
+	ResponseStreamError   string              `json:"resp_stream_error,omitempty"`
 }
 
 func PrintResponse(logger Logger, req *http.Request, reqBody []byte, respStatusCode int, respHeaders map[string][]string, respBody []byte) error {
+	return printLog(logger, req, reqBody, &ResponseLog{
+		ResponseStatusCode: respStatusCode,
+		ResponseHeaders:    respHeaders,
+		ResponseBody:       string(respBody),
+	})
+}
+
+// PrintStreamedResponse logs response whose body was streamed to client, respBody holds only its captured beginning
+func PrintStreamedResponse(logger Logger, req *http.Request, reqBody []byte, respStatusCode int, respHeaders map[string][]string, respBody []byte, truncated bool, streamErr error) error {
+	responseLog := &ResponseLog{
+		ResponseStatusCode:    respStatusCode,
+		ResponseHeaders:       respHeaders,
+		ResponseBody:          string(respBody),
+		ResponseBodyTruncated: truncated,
+	}
+
+	if streamErr != nil {
+		responseLog.ResponseStreamError = streamErr.Error()
+	}
+
+	return printLog(logger, req, reqBody, responseLog)
+}
+
+func printLog(logger Logger, req *http.Request, reqBody []byte, responseLog *ResponseLog) error {
 	buffRequest := bytes.NewBuffer(nil)
 
 	err := json.NewEncoder(buffRequest).Encode(&RequestLog{
@@ -42,11 +68,7 @@ func PrintResponse(logger Logger, req *http.Request, reqBody []byte, respStatusC
 
 	buffResponse := bytes.NewBuffer(nil)
 
-	err = json.NewEncoder(buffResponse).Encode(&ResponseLog{
-		ResponseStatusCode: respStatusCode,
-		ResponseHeaders:    respHeaders,
-		ResponseBody:       string(respBody),
-	})
+	err = json.NewEncoder(buffResponse).Encode(responseLog)
 
 	if err != nil {
 		return fmt.Errorf("%s : %s", ErrJSONMarshalResp, err)
@@ -4,6 +4,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/bradleyjkemp/cupaloy/v2"
@@ -78,3 +79,34 @@ func TestLogger(t *testing.T) {
 		}
 	}
 }
+
+func TestLoggerStream(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPut, "http://localhost:8000", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to create req %s", err)
+	}
+
+	logger := &LoggerMock{}
+	recorder := httptest.NewRecorder()
+
+	factory := log.ResponseWriterFactoryInstance{
+		Logger:             logger,
+		MaxStreamedBodyLog: 4,
+	}
+
+	respBody := "streamed body"
+
+	factory.New(req, nil, recorder).Stream(http.StatusOK, map[string][]string{
+		"Response-Header": {"responseHeader"},
+	}, strings.NewReader(respBody))
+
+	assert.Equal(t, respBody, recorder.Body.String(), "expected whole body to be streamed to resp")
+
+	assert.Equal(t, "responseHeader", recorder.Header().Get("Response-Header"), "expected headers to be written")
+
+	assert.True(t, recorder.Flushed, "expected streamed resp to be flushed")
+
+	assert.Contains(t, logger.Data, `"resp_body":"stre"`, "expected only beginning of streamed body to be logged")
+
+	assert.Contains(t, logger.Data, `"resp_body_truncated":true`, "expected log to mark truncated body")
+}
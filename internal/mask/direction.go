@@ -0,0 +1,33 @@
+package mask
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownDirection = errors.New("unknown masking direction")
+
+// Direction tells which bodies of a request get masked
+type Direction string
+
+const DirectionNone = Direction("none")
+const DirectionRequest = Direction("request")
+const DirectionResponse = Direction("response")
+const DirectionBoth = Direction("both")
+
+func ParseDirection(direction string) (Direction, error) {
+	switch Direction(direction) {
+	case DirectionNone, DirectionRequest, DirectionResponse, DirectionBoth:
+		return Direction(direction), nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnknownDirection, direction)
+}
+
+func (direction Direction) MasksRequest() bool {
+	return direction == DirectionRequest || direction == DirectionBoth
+}
+
+func (direction Direction) MasksResponse() bool {
+	return direction == DirectionResponse || direction == DirectionBoth
+}
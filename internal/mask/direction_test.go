@@ -0,0 +1,53 @@
+package mask_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vjerci/reverse-proxy/internal/mask"
+)
+
+func TestParseDirection(t *testing.T) {
+	testCases := []struct {
+		input            string
+		expectedRequest  bool
+		expectedResponse bool
+	}{
+		{
+			input:            "none",
+			expectedRequest:  false,
+			expectedResponse: false,
+		},
+		{
+			input:            "request",
+			expectedRequest:  true,
+			expectedResponse: false,
+		},
+		{
+			input:            "response",
+			expectedRequest:  false,
+			expectedResponse: true,
+		},
+		{
+			input:            "both",
+			expectedRequest:  true,
+			expectedResponse: true,
+		},
+	}
+
+	for _, test := range testCases {
+		direction, err := mask.ParseDirection(test.input)
+		if err != nil {
+			t.Fatalf("failed to parse direction %s got %s", test.input, err)
+		}
+
+		if direction.MasksRequest() != test.expectedRequest || direction.MasksResponse() != test.expectedResponse {
+			t.Fatalf("for direction %s expected request %t and response %t", test.input, test.expectedRequest, test.expectedResponse)
+		}
+	}
+
+	_, err := mask.ParseDirection("sideways")
+	if !errors.Is(err, mask.ErrUnknownDirection) {
+		t.Fatalf("expected ErrUnknownDirection got %s instead", err)
+	}
+}
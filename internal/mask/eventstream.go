@@ -0,0 +1,139 @@
+package mask
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrDecodeEventStream = errors.New("failed to decode event stream")
+var ErrWriteEventStream = errors.New("failed to write event stream")
+
+var eventDataField = []byte("data")
+
+// EventStreamInspector masks json carried in data lines of text/event-stream, every event is masked on its own
+// and written out as soon as the blank line ending it arrives.
+// Data split over several lines is joined and written back as a single data line, data which isn't json, like "[DONE]", is kept as it was.
+// Other fields and comments are copied unchanged
+type EventStreamInspector struct {
+	dataInspector Inspector
+}
+
+// NewEventStreamInspector masks data of events with dataInspector, which is usually json inspector sharing rules with other inspectors
+func NewEventStreamInspector(dataInspector Inspector) StreamInspector {
+	return &EventStreamInspector{
+		dataInspector: dataInspector,
+	}
+}
+
+func (inspector *EventStreamInspector) Inspect(input []byte) ([]byte, error) {
+	buff := bytes.NewBuffer(nil)
+
+	err := inspector.InspectStream(bytes.NewReader(input), buff)
+	if err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
+
+func (inspector *EventStreamInspector) InspectStream(input io.Reader, output io.Writer) error {
+	reader := bufio.NewReader(input)
+
+	var event [][]byte
+
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("%w: %w", ErrDecodeEventStream, readErr)
+		}
+
+		if len(line) > 0 {
+			event = append(event, line)
+		}
+
+		content, _ := splitLineEnding(line)
+
+		// blank line dispatches the event, unfinished event at the end of stream is flushed as it is
+		if (len(content) == 0 || readErr == io.EOF) && len(event) > 0 {
+			err := inspector.writeEvent(event, output)
+			if err != nil {
+				return err
+			}
+
+			event = nil
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+// writeEvent writes lines of single event, masked data takes place of the first data line
+func (inspector *EventStreamInspector) writeEvent(event [][]byte, output io.Writer) error {
+	var dataLines [][]byte
+
+	for _, line := range event {
+		value, isData := eventData(line)
+		if isData {
+			dataLines = append(dataLines, value)
+		}
+	}
+
+	data := bytes.Join(dataLines, []byte("\n"))
+
+	masked := data
+	if len(dataLines) > 0 && json.Valid(data) {
+		var err error
+
+		masked, err = inspector.dataInspector.Inspect(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	buff := bytes.NewBuffer(nil)
+	dataWritten := false
+
+	for _, line := range event {
+		_, isData := eventData(line)
+
+		switch {
+		case !isData || bytes.Equal(masked, data):
+			// unmasked data is copied line by line so it keeps its original form
+			buff.Write(line)
+		case !dataWritten:
+			_, ending := splitLineEnding(line)
+
+			buff.WriteString("data: ")
+			buff.Write(masked)
+			buff.Write(ending)
+
+			dataWritten = true
+		}
+	}
+
+	// each event is written on its own so it reaches the client right away
+	_, err := output.Write(buff.Bytes())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteEventStream, err)
+	}
+
+	return nil
+}
+
+// eventData returns value of data field, single space after the colon isn't part of the value
+func eventData(line []byte) ([]byte, bool) {
+	content, _ := splitLineEnding(line)
+
+	name, value, _ := bytes.Cut(content, []byte(":"))
+	if !bytes.Equal(name, eventDataField) {
+		return nil, false
+	}
+
+	return bytes.TrimPrefix(value, []byte(" ")), true
+}
@@ -0,0 +1,85 @@
+package mask
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestEventStreamInspector(t *testing.T) {
+	eventStreamInspector := NewEventStreamInspector(NewJSONInspector(NewJSONMask(), NewPIIClassifier(NewDefaultPIIPatterns())))
+
+	testCases := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{
+			testName: "events",
+			input:    "event: user\nid: 1\ndata: {\"name\": \"mark\"}\n\n: keep alive\n\ndata:{\"name\": \"john\", \"id\": 2}\n\n",
+			expected: "event: user\nid: 1\ndata: {\"name\":\"x\"}\n\n: keep alive\n\ndata: {\"name\":\"x\",\"id\":2}\n\n",
+		},
+		{
+			testName: "multi_line_data",
+			input:    "data: {\"name\":\ndata: \"mark\"}\nid: 3\r\n\r\n",
+			expected: "data: {\"name\":\"x\"}\nid: 3\r\n\r\n",
+		},
+		{
+			testName: "not_json",
+			input:    "data: [DONE]\ndata: name\n\ndata\n\n",
+			expected: "data: [DONE]\ndata: name\n\ndata\n\n",
+		},
+		{
+			testName: "unfinished_event",
+			input:    "data: {\"name\": \"mark\"}",
+			expected: "data: {\"name\":\"x\"}",
+		},
+	}
+
+	for _, test := range testCases {
+		output, err := eventStreamInspector.Inspect([]byte(test.input))
+		if err != nil {
+			t.Fatalf("for test %s expected to inspect event stream got %s instead", test.testName, err)
+		}
+
+		if string(output) != test.expected {
+			t.Fatalf("for test %s expected %q got %q", test.testName, test.expected, output)
+		}
+	}
+}
+
+func TestEventStreamInspectorStream(t *testing.T) {
+	eventStreamInspector := NewEventStreamInspector(NewJSONInspector(NewJSONMask(), NewPIIClassifier(NewDefaultPIIPatterns())))
+
+	inputReader, inputWriter := io.Pipe()
+	outputReader, outputWriter := io.Pipe()
+
+	go func() {
+		outputWriter.CloseWithError(eventStreamInspector.InspectStream(inputReader, outputWriter))
+	}()
+
+	output := bufio.NewReader(outputReader)
+
+	_, err := inputWriter.Write([]byte("data: {\"name\": \"mark\"}\n\n"))
+	if err != nil {
+		t.Fatalf("failed to write event %s", err)
+	}
+
+	// event is masked while upstream still hasn't finished
+	masked, err := output.ReadString('\n')
+	if err != nil {
+		t.Fatalf("expected masked event before end of input got %s instead", err)
+	}
+
+	if masked != "data: {\"name\":\"x\"}\n" {
+		t.Fatalf("expected masked event got %q instead", masked)
+	}
+
+	inputWriter.CloseWithError(errors.New("upstream went away"))
+
+	_, err = io.ReadAll(output)
+	if !errors.Is(err, ErrDecodeEventStream) {
+		t.Fatalf("expected ErrDecodeEventStream got %s instead", err)
+	}
+}
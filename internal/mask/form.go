@@ -0,0 +1,327 @@
+package mask
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/url"
+	"strings"
+)
+
+var ErrDecodeForm = errors.New("failed to decode form")
+var ErrWriteForm = errors.New("failed to write form")
+var ErrUnknownTransferEncoding = errors.New("unknown content transfer encoding")
+
+// FormInspector masks values of application/x-www-form-urlencoded fields classified by field name or value,
+// order of fields and encoding of fields which aren't masked are kept
+type FormInspector struct {
+	rules []Rule
+}
+
+func NewFormRulesInspector(rules []Rule) StreamInspector {
+	return &FormInspector{
+		rules: rules,
+	}
+}
+
+func (inspector *FormInspector) Inspect(input []byte) ([]byte, error) {
+	buff := bytes.NewBuffer(nil)
+
+	err := inspector.InspectStream(bytes.NewReader(input), buff)
+	if err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
+
+func (inspector *FormInspector) InspectStream(input io.Reader, output io.Writer) error {
+	reader := bufio.NewReader(input)
+	writer := bufio.NewWriter(output)
+
+	for written := false; ; {
+		pair, readErr := reader.ReadString('&')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("%w: %w", ErrDecodeForm, readErr)
+		}
+
+		pair = strings.TrimSuffix(pair, "&")
+
+		if pair != "" {
+			masked, keep, err := inspector.inspectPair(pair)
+			if err != nil {
+				return err
+			}
+
+			if keep {
+				if written {
+					masked = "&" + masked
+				}
+
+				_, err = writer.WriteString(masked)
+				if err != nil {
+					return fmt.Errorf("%w: %w", ErrWriteForm, err)
+				}
+
+				written = true
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	err := writer.Flush()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteForm, err)
+	}
+
+	return nil
+}
+
+// inspectPair masks value of "name=value" pair, pair dropped by its mask isn't kept
+func (inspector *FormInspector) inspectPair(pair string) (masked string, keep bool, err error) {
+	rawName, rawValue, _ := strings.Cut(pair, "=")
+
+	name, err := url.QueryUnescape(rawName)
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %w", ErrDecodeForm, err)
+	}
+
+	value, err := url.QueryUnescape(rawValue)
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %w", ErrDecodeForm, err)
+	}
+
+	rule := firstMatchingRule(inspector.rules, Path{{Key: name}}, value, FieldTypeString)
+	if rule == nil {
+		return pair, true, nil
+	}
+
+	if dropsValue(rule) {
+		return "", false, nil
+	}
+
+	return rawName + "=" + url.QueryEscape(maskedText(rule, value)), true, nil
+}
+
+// MultipartInspector masks values of multipart/form-data fields classified by field name or value.
+// File parts are copied intact or dropped when dropFiles is set. Boundary is taken from content type of the body,
+// so inspector has to be bound to it with ForContentType, registry does that on lookup.
+// Fields are read whole up to multipartMaxFieldSize, base64 and quoted-printable fields are decoded before they are
+// classified and masked values are encoded back, fields with other transfer encodings are refused
+type MultipartInspector struct {
+	rules     []Rule
+	dropFiles bool
+	boundary  string
+}
+
+// multipartMaxFieldSize limits size of field part held in memory while it's masked, files aren't limited as they are copied
+const multipartMaxFieldSize = 1 << 20
+
+func NewMultipartRulesInspector(rules []Rule, dropFiles bool) StreamInspector {
+	return &MultipartInspector{
+		rules:     rules,
+		dropFiles: dropFiles,
+	}
+}
+
+// ForContentType returns inspector using boundary parameter of contentType, body inspected without boundary is refused
+func (inspector *MultipartInspector) ForContentType(contentType string) Inspector {
+	bound := *inspector
+	bound.boundary = ""
+
+	_, params, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		bound.boundary = params["boundary"]
+	}
+
+	return &bound
+}
+
+func (inspector *MultipartInspector) Inspect(input []byte) ([]byte, error) {
+	buff := bytes.NewBuffer(nil)
+
+	err := inspector.InspectStream(bytes.NewReader(input), buff)
+	if err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
+
+func (inspector *MultipartInspector) InspectStream(input io.Reader, output io.Writer) error {
+	if inspector.boundary == "" {
+		return fmt.Errorf("%w: content type has no multipart boundary", ErrDecodeForm)
+	}
+
+	bufferedOutput := bufio.NewWriter(output)
+
+	multipartReader := multipart.NewReader(input, inspector.boundary)
+	multipartWriter := multipart.NewWriter(bufferedOutput)
+
+	err := multipartWriter.SetBoundary(inspector.boundary)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteForm, err)
+	}
+
+	for {
+		// raw part keeps transfer encoding of the part as it was
+		part, err := multipartReader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrDecodeForm, err)
+		}
+
+		err = inspector.inspectPart(multipartWriter, part)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = multipartWriter.Close()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteForm, err)
+	}
+
+	err = bufferedOutput.Flush()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteForm, err)
+	}
+
+	return nil
+}
+
+func (inspector *MultipartInspector) inspectPart(writer *multipart.Writer, part *multipart.Part) error {
+	if part.FileName() != "" {
+		if inspector.dropFiles {
+			return nil
+		}
+
+		partWriter, err := writer.CreatePart(part.Header)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrWriteForm, err)
+		}
+
+		_, err = io.Copy(partWriter, part)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrDecodeForm, err)
+		}
+
+		return nil
+	}
+
+	content, err := io.ReadAll(io.LimitReader(part, multipartMaxFieldSize+1))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDecodeForm, err)
+	}
+
+	if len(content) > multipartMaxFieldSize {
+		return fmt.Errorf("%w: field %s is larger than %d bytes", ErrDecodeForm, part.FormName(), multipartMaxFieldSize)
+	}
+
+	transferEncoding := strings.ToLower(strings.TrimSpace(part.Header.Get("Content-Transfer-Encoding")))
+
+	value, err := decodeTransferEncoding(transferEncoding, content)
+	if err != nil {
+		return fmt.Errorf("%w: field %s: %w", ErrDecodeForm, part.FormName(), err)
+	}
+
+	rule := firstMatchingRule(inspector.rules, Path{{Key: part.FormName()}}, value, FieldTypeString)
+	if rule != nil {
+		if dropsValue(rule) {
+			return nil
+		}
+
+		content, err = encodeTransferEncoding(transferEncoding, maskedText(rule, value))
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrWriteForm, err)
+		}
+
+		part.Header.Del("Content-Length")
+	}
+
+	partWriter, err := writer.CreatePart(part.Header)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteForm, err)
+	}
+
+	_, err = partWriter.Write(content)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteForm, err)
+	}
+
+	return nil
+}
+
+// decodeTransferEncoding decodes field content by its Content-Transfer-Encoding, identity encodings are kept as they are
+func decodeTransferEncoding(transferEncoding string, content []byte) (string, error) {
+	switch transferEncoding {
+	case "", "7bit", "8bit", "binary":
+		return string(content), nil
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(content)), ""))
+		if err != nil {
+			return "", err
+		}
+
+		return string(decoded), nil
+	case "quoted-printable":
+		decoded, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(content)))
+		if err != nil {
+			return "", err
+		}
+
+		return string(decoded), nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnknownTransferEncoding, transferEncoding)
+}
+
+// encodeTransferEncoding encodes masked value back to Content-Transfer-Encoding of its field
+func encodeTransferEncoding(transferEncoding string, value string) ([]byte, error) {
+	switch transferEncoding {
+	case "base64":
+		return []byte(base64.StdEncoding.EncodeToString([]byte(value))), nil
+	case "quoted-printable":
+		buff := bytes.NewBuffer(nil)
+
+		writer := quotedprintable.NewWriter(buff)
+
+		_, err := io.WriteString(writer, value)
+		if err != nil {
+			return nil, err
+		}
+
+		err = writer.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		return buff.Bytes(), nil
+	}
+
+	return []byte(value), nil
+}
+
+// dropsValue reports whether rule removes the field instead of replacing its value
+func dropsValue(rule *Rule) bool {
+	wholeMask, ok := rule.Mask.(WholeMask)
+	if !ok {
+		return false
+	}
+
+	_, drop := wholeMask.MaskWhole(FieldTypeString)
+
+	return drop
+}
@@ -0,0 +1,257 @@
+package mask
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func formRules() []Rule {
+	return []Rule{
+		{
+			Classifier: NewPIIClassifier([]PIIPattern{&PIIClassifierPattern{Regexp: regexp.MustCompile(`^password$`)}}),
+			Mask:       &DropMask{},
+		},
+		{
+			Classifier: NewPIIClassifier(NewDefaultPIIPatterns()),
+			Values:     NewPIIValueClassifier(NewDefaultPIIValuePatterns()),
+			Mask:       &JSONMask{String: &FixedStringMask{Value: "a b&c"}},
+		},
+	}
+}
+
+func TestFormInspector(t *testing.T) {
+	formInspector := NewFormRulesInspector(formRules())
+
+	testCases := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{
+			testName: "login",
+			input:    "password=secret&username=mark%20s&remember=on&contact=mark%40domain.com",
+			expected: "username=a+b%26c&remember=on&contact=a+b%26c",
+		},
+		{
+			testName: "unmasked_encoding_kept",
+			input:    "q=a+b%2Fc&&page=2",
+			expected: "q=a+b%2Fc&page=2",
+		},
+		{
+			testName: "empty",
+			input:    "",
+			expected: "",
+		},
+	}
+
+	for _, test := range testCases {
+		output, err := formInspector.Inspect([]byte(test.input))
+		if err != nil {
+			t.Fatalf("for test %s expected to inspect form got %s instead", test.testName, err)
+		}
+
+		if string(output) != test.expected {
+			t.Fatalf("for test %s expected %s got %s", test.testName, test.expected, output)
+		}
+	}
+
+	_, err := formInspector.Inspect([]byte("name=%zz"))
+	if !errors.Is(err, ErrDecodeForm) {
+		t.Fatalf("expected ErrDecodeForm got %s instead", err)
+	}
+}
+
+func TestMultipartInspector(t *testing.T) {
+	input := strings.ReplaceAll(`--XYZ
+Content-Disposition: form-data; name="username"
+
+mark
+--XYZ
+Content-Disposition: form-data; name="password"
+
+secret
+--XYZ
+Content-Disposition: form-data; name="age"
+
+30
+--XYZ
+Content-Disposition: form-data; name="avatar"; filename="mark.png"
+Content-Type: image/png
+
+PNG mark@domain.com
+--XYZ--
+`, "\n", "\r\n")
+
+	testCases := []struct {
+		testName  string
+		dropFiles bool
+		expected  string
+	}{
+		{
+			testName: "files_kept",
+			expected: strings.ReplaceAll(`--XYZ
+Content-Disposition: form-data; name="username"
+
+a b&c
+--XYZ
+Content-Disposition: form-data; name="age"
+
+30
+--XYZ
+Content-Disposition: form-data; name="avatar"; filename="mark.png"
+Content-Type: image/png
+
+PNG mark@domain.com
+--XYZ--
+`, "\n", "\r\n"),
+		},
+		{
+			testName:  "files_dropped",
+			dropFiles: true,
+			expected: strings.ReplaceAll(`--XYZ
+Content-Disposition: form-data; name="username"
+
+a b&c
+--XYZ
+Content-Disposition: form-data; name="age"
+
+30
+--XYZ--
+`, "\n", "\r\n"),
+		},
+	}
+
+	for _, test := range testCases {
+		output, err := multipartInspector(test.dropFiles, "multipart/form-data; boundary=XYZ").Inspect([]byte(input))
+		if err != nil {
+			t.Fatalf("for test %s expected to inspect multipart got %s instead", test.testName, err)
+		}
+
+		if string(output) != test.expected {
+			t.Fatalf("for test %s expected %q got %q", test.testName, test.expected, output)
+		}
+	}
+
+	for _, contentType := range []string{"multipart/form-data", "multipart/form-data; boundary=", "multipart/form-data; boundary"} {
+		_, err := multipartInspector(false, contentType).Inspect([]byte(input))
+		if !errors.Is(err, ErrDecodeForm) {
+			t.Fatalf("for content type %q expected ErrDecodeForm got %s instead", contentType, err)
+		}
+	}
+
+	_, err := NewMultipartRulesInspector(formRules(), false).Inspect([]byte(input))
+	if !errors.Is(err, ErrDecodeForm) {
+		t.Fatalf("expected ErrDecodeForm for inspector without content type got %s instead", err)
+	}
+}
+
+func TestMultipartInspectorBoundary(t *testing.T) {
+	// preamble and boundary line which isn't the declared boundary aren't taken for boundary
+	input := strings.ReplaceAll(`--preamble
+--XYZ
+Content-Disposition: form-data; name="username"
+
+mark
+--XYZ--
+`, "\n", "\r\n")
+
+	expected := strings.ReplaceAll(`--XYZ
+Content-Disposition: form-data; name="username"
+
+a b&c
+--XYZ--
+`, "\n", "\r\n")
+
+	output, err := multipartInspector(false, `multipart/form-data; boundary="XYZ"`).Inspect([]byte(input))
+	if err != nil {
+		t.Fatalf("expected to inspect multipart with preamble got %s instead", err)
+	}
+
+	if string(output) != expected {
+		t.Fatalf("expected %q got %q", expected, output)
+	}
+}
+
+func TestMultipartInspectorTransferEncoding(t *testing.T) {
+	testCases := []struct {
+		testName string
+		encoding string
+		value    string
+		expected string
+	}{
+		{
+			testName: "base64",
+			encoding: "base64",
+			value:    "bWFya0Bkb21haW4uY29t",
+			expected: "YSBiJmM=",
+		},
+		{
+			testName: "quoted_printable",
+			encoding: "Quoted-Printable",
+			value:    "mark=40domain.com",
+			expected: "a b&c",
+		},
+		{
+			testName: "not_masked",
+			encoding: "base64",
+			value:    "MzA=",
+			expected: "MzA=",
+		},
+	}
+
+	for _, test := range testCases {
+		input := strings.ReplaceAll(`--XYZ
+Content-Disposition: form-data; name="note"
+Content-Transfer-Encoding: `+test.encoding+`
+
+`+test.value+`
+--XYZ--
+`, "\n", "\r\n")
+
+		expected := strings.ReplaceAll(`--XYZ
+Content-Disposition: form-data; name="note"
+Content-Transfer-Encoding: `+test.encoding+`
+
+`+test.expected+`
+--XYZ--
+`, "\n", "\r\n")
+
+		output, err := multipartInspector(false, "multipart/form-data; boundary=XYZ").Inspect([]byte(input))
+		if err != nil {
+			t.Fatalf("for test %s expected to inspect multipart got %s instead", test.testName, err)
+		}
+
+		if string(output) != expected {
+			t.Fatalf("for test %s expected %q got %q", test.testName, expected, output)
+		}
+	}
+
+	input := strings.ReplaceAll(`--XYZ
+Content-Disposition: form-data; name="note"
+Content-Transfer-Encoding: x-uuencode
+
+mark@domain.com
+--XYZ--
+`, "\n", "\r\n")
+
+	_, err := multipartInspector(false, "multipart/form-data; boundary=XYZ").Inspect([]byte(input))
+	if !errors.Is(err, ErrUnknownTransferEncoding) {
+		t.Fatalf("expected ErrUnknownTransferEncoding got %s instead", err)
+	}
+}
+
+func TestMultipartInspectorFieldSize(t *testing.T) {
+	input := "--XYZ\r\nContent-Disposition: form-data; name=\"note\"\r\n\r\n" +
+		strings.Repeat("a", multipartMaxFieldSize+1) + "\r\n--XYZ--\r\n"
+
+	_, err := multipartInspector(false, "multipart/form-data; boundary=XYZ").Inspect([]byte(input))
+	if !errors.Is(err, ErrDecodeForm) {
+		t.Fatalf("expected ErrDecodeForm for oversized field got %s instead", err)
+	}
+}
+
+func multipartInspector(dropFiles bool, contentType string) Inspector {
+	return NewMultipartRulesInspector(formRules(), dropFiles).(ContentTypeInspector).ForContentType(contentType)
+}
@@ -1,10 +1,13 @@
 package mask
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"strconv"
 )
 
 type FieldType string
@@ -12,78 +15,386 @@ type FieldType string
 var FieldTypeString = FieldType("string")
 var FieldTypeBool = FieldType("bool")
 var FieldTypeFloat64 = FieldType("float64")
+var FieldTypeObject = FieldType("object")
+var FieldTypeArray = FieldType("array")
+var FieldTypeNull = FieldType("null")
 
 var ErrDecodeJSON = errors.New("failed to decode json")
+var ErrWriteJSON = errors.New("failed to write json")
 
 type Inspector interface {
 	Inspect(bytes []byte) ([]byte, error)
 }
 
+// StreamInspector masks input while copying it to output without holding whole document in memory
+type StreamInspector interface {
+	Inspector
+	InspectStream(input io.Reader, output io.Writer) error
+}
+
+// ContentTypeInspector needs parameters of body content type, like multipart boundary.
+// Registry hands out inspector bound to content type it was looked up for
+type ContentTypeInspector interface {
+	Inspector
+	ForContentType(contentType string) Inspector
+}
+
+// JSONInspector walks json token by token, it keeps key order and number formatting of the input
 type JSONInspector struct {
-	masker     Mask
-	classifier Classifier
+	rules        []Rule
+	exactNumbers bool
+}
+
+func NewJSONInspector(mask Mask, classifier Classifier) StreamInspector {
+	return NewJSONRulesInspector([]Rule{
+		{
+			Classifier: classifier,
+			Mask:       mask,
+		},
+	})
 }
 
-func NewJSONInspector(mask Mask, classifier Classifier) Inspector {
+// NewJSONRulesInspector masks each field with mask of the first rule that matches it
+func NewJSONRulesInspector(rules []Rule) StreamInspector {
 	return &JSONInspector{
-		masker:     mask,
-		classifier: classifier,
+		rules: rules,
 	}
 }
 
-func (inspector *JSONInspector) Inspect(input []byte) ([]byte, error) {
-	var data interface{}
-	err := json.NewDecoder(bytes.NewBuffer(input)).Decode(&data)
-	if err != nil {
-		return nil, fmt.Errorf("%w: %w", ErrDecodeJSON, err)
+// NewExactJSONRulesInspector passes classified numbers to masks as json.Number instead of float64,
+// so masks deriving output from input, like tokens, don't see integers beyond 2^53 rounded
+func NewExactJSONRulesInspector(rules []Rule) StreamInspector {
+	return &JSONInspector{
+		rules:        rules,
+		exactNumbers: true,
 	}
+}
 
-	data = inspector.inspectInput(data)
+// rule returns first rule matching the field or nil when field isn't classified.
+// objects and arrays are matched only by rules which mask them as a whole, others look inside them
+func (inspector *JSONInspector) rule(path Path, value string, fieldType FieldType) *Rule {
+	return firstMatchingRule(inspector.rules, path, value, fieldType)
+}
 
+func (inspector *JSONInspector) Inspect(input []byte) ([]byte, error) {
 	buff := bytes.NewBuffer(nil)
-	json.NewEncoder(buff).Encode(&data)
+
+	err := inspector.InspectStream(bytes.NewReader(input), buff)
+	if err != nil {
+		return nil, err
+	}
 
 	return buff.Bytes(), nil
 }
 
-func (inspector *JSONInspector) inspectInput(input interface{}) interface{} {
-	switch inputTyped := input.(type) {
-	case map[string]interface{}:
-		return inspector.inspectMap(inputTyped)
-	case []interface{}:
-		return inspector.inspectArray(inputTyped)
-	default:
-		return input
+func (inspector *JSONInspector) InspectStream(input io.Reader, output io.Writer) error {
+	decoder := json.NewDecoder(input)
+	decoder.UseNumber()
+
+	writer := newJSONWriter(output)
+
+	err := inspector.inspectValue(decoder, writer, Path{})
+	if err != nil {
+		return err
 	}
+
+	_, err = decoder.Token()
+	if err != io.EOF {
+		return fmt.Errorf("%w: unexpected data after top level value", ErrDecodeJSON)
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteJSON, err)
+	}
+
+	return nil
 }
 
-func (inspector *JSONInspector) inspectMap(input map[string]interface{}) map[string]interface{} {
-	for key, value := range input {
-		switch value.(type) {
-		case float64:
-			if inspector.classifier.ClassifyField(key, FieldTypeFloat64) {
-				input[key] = inspector.masker.Mask(value, FieldTypeFloat64)
-			}
-		case string:
-			if inspector.classifier.ClassifyField(key, FieldTypeString) {
-				input[key] = inspector.masker.Mask(value, FieldTypeString)
-			}
-		case bool:
-			if inspector.classifier.ClassifyField(key, FieldTypeBool) {
-				input[key] = inspector.masker.Mask(value, FieldTypeBool)
+// inspectValue copies next value from decoder, top level value dropped by its mask is written as null
+func (inspector *JSONInspector) inspectValue(decoder *json.Decoder, writer *jsonWriter, path Path) error {
+	written, err := inspector.inspectMember(decoder, writer, path, "")
+	if err != nil || written {
+		return err
+	}
+
+	return writer.WriteRaw("null")
+}
+
+// inspectMember copies next value from decoder, values are classified by their path and by their value.
+// prefix, like separator and key of object member, is written before value unless value is dropped by its mask
+func (inspector *JSONInspector) inspectMember(decoder *json.Decoder, writer *jsonWriter, path Path, prefix string) (written bool, err error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrDecodeJSON, err)
+	}
+
+	fieldType, value := tokenFieldType(token)
+
+	rule := inspector.rule(path, value, fieldType)
+
+	if rule != nil {
+		wholeMask, ok := rule.Mask.(WholeMask)
+		if ok {
+			return inspector.maskWhole(decoder, writer, token, fieldType, wholeMask, prefix)
+		}
+	}
+
+	err = writer.WriteRaw(prefix)
+	if err != nil {
+		return false, err
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		if value == '{' {
+			return true, inspector.inspectObject(decoder, writer, path)
+		}
+
+		return true, inspector.inspectArray(decoder, writer, path)
+	case string:
+		if rule != nil {
+			return true, writer.WriteValue(rule.Mask.Mask(value, FieldTypeString))
+		}
+
+		return true, writer.WriteValue(value)
+	case json.Number:
+		if rule != nil && inspector.exactNumbers {
+			return true, writer.WriteValue(rule.Mask.Mask(value, FieldTypeFloat64))
+		}
+
+		if rule != nil {
+			number, err := value.Float64()
+			if err != nil {
+				return false, fmt.Errorf("%w: %w", ErrDecodeJSON, err)
 			}
-		default:
-			input[key] = inspector.inspectInput(value)
+
+			return true, writer.WriteValue(rule.Mask.Mask(number, FieldTypeFloat64))
+		}
+
+		return true, writer.WriteRaw(value.String())
+	case bool:
+		if rule != nil {
+			return true, writer.WriteValue(rule.Mask.Mask(value, FieldTypeBool))
 		}
+
+		return true, writer.WriteValue(value)
+	default:
+		if rule != nil {
+			return true, writer.WriteValue(rule.Mask.Mask(nil, FieldTypeNull))
+		}
+
+		return true, writer.WriteRaw("null")
+	}
+}
+
+// maskWhole replaces value without looking inside it, objects and arrays are skipped in decoder
+func (inspector *JSONInspector) maskWhole(decoder *json.Decoder, writer *jsonWriter, token json.Token, fieldType FieldType, wholeMask WholeMask, prefix string) (bool, error) {
+	err := skipValue(decoder, token)
+	if err != nil {
+		return false, err
+	}
+
+	replacement, drop := wholeMask.MaskWhole(fieldType)
+	if drop {
+		return false, nil
+	}
+
+	err = writer.WriteRaw(prefix)
+	if err != nil {
+		return false, err
 	}
 
-	return input
+	return true, writer.WriteValue(replacement)
 }
 
-func (inspector *JSONInspector) inspectArray(input []interface{}) []interface{} {
-	for key, value := range input {
-		input[key] = inspector.inspectInput(value)
+func (inspector *JSONInspector) inspectObject(decoder *json.Decoder, writer *jsonWriter, path Path) error {
+	err := writer.WriteRaw("{")
+	if err != nil {
+		return err
 	}
 
-	return input
+	for written := false; decoder.More(); {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrDecodeJSON, err)
+		}
+
+		key, ok := token.(string)
+		if !ok {
+			return fmt.Errorf("%w: expected object key got %v", ErrDecodeJSON, token)
+		}
+
+		prefix, err := memberPrefix(writer, key, written)
+		if err != nil {
+			return err
+		}
+
+		// siblings reuse the same slot of path since they are inspected one after another
+		memberWritten, err := inspector.inspectMember(decoder, writer, append(path, PathSegment{Key: key}), prefix)
+		if err != nil {
+			return err
+		}
+
+		written = written || memberWritten
+	}
+
+	return inspector.closeDelim(decoder, writer, '}')
+}
+
+func (inspector *JSONInspector) inspectArray(decoder *json.Decoder, writer *jsonWriter, path Path) error {
+	err := writer.WriteRaw("[")
+	if err != nil {
+		return err
+	}
+
+	for index, written := 0, false; decoder.More(); index++ {
+		prefix := ""
+		if written {
+			prefix = ","
+		}
+
+		elementWritten, err := inspector.inspectMember(decoder, writer, append(path, PathSegment{Index: index, IsIndex: true}), prefix)
+		if err != nil {
+			return err
+		}
+
+		written = written || elementWritten
+	}
+
+	return inspector.closeDelim(decoder, writer, ']')
+}
+
+func (inspector *JSONInspector) closeDelim(decoder *json.Decoder, writer *jsonWriter, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDecodeJSON, err)
+	}
+
+	if token != delim {
+		return fmt.Errorf("%w: expected %s got %v", ErrDecodeJSON, delim, token)
+	}
+
+	return writer.WriteRaw(delim.String())
+}
+
+// tokenFieldType returns type of value starting with token and its text used for classification by value
+func tokenFieldType(token json.Token) (FieldType, string) {
+	switch value := token.(type) {
+	case json.Delim:
+		if value == '{' {
+			return FieldTypeObject, ""
+		}
+
+		return FieldTypeArray, ""
+	case string:
+		return FieldTypeString, value
+	case json.Number:
+		return FieldTypeFloat64, value.String()
+	case bool:
+		return FieldTypeBool, strconv.FormatBool(value)
+	}
+
+	return FieldTypeNull, ""
+}
+
+// skipValue reads rest of value starting with token from decoder
+func skipValue(decoder *json.Decoder, token json.Token) error {
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	for depth := 1; depth > 0; {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrDecodeJSON, err)
+		}
+
+		delim, ok = token.(json.Delim)
+		if !ok {
+			continue
+		}
+
+		if delim == '{' || delim == '[' {
+			depth++
+		} else {
+			depth--
+		}
+	}
+
+	return nil
+}
+
+func memberPrefix(writer *jsonWriter, key string, afterMember bool) (string, error) {
+	encoded, err := writer.encode(key)
+	if err != nil {
+		return "", err
+	}
+
+	prefix := string(encoded) + ":"
+	if afterMember {
+		prefix = "," + prefix
+	}
+
+	return prefix, nil
+}
+
+type jsonWriter struct {
+	writer  *bufio.Writer
+	scratch *bytes.Buffer
+	encoder *json.Encoder
+}
+
+func newJSONWriter(output io.Writer) *jsonWriter {
+	scratch := bytes.NewBuffer(nil)
+
+	encoder := json.NewEncoder(scratch)
+	encoder.SetEscapeHTML(false)
+
+	return &jsonWriter{
+		writer:  bufio.NewWriter(output),
+		scratch: scratch,
+		encoder: encoder,
+	}
+}
+
+func (writer *jsonWriter) WriteRaw(raw string) error {
+	_, err := writer.writer.WriteString(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteJSON, err)
+	}
+
+	return nil
+}
+
+func (writer *jsonWriter) WriteValue(value interface{}) error {
+	encoded, err := writer.encode(value)
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.writer.Write(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteJSON, err)
+	}
+
+	return nil
+}
+
+// encode returns json of value, it is valid only until next call
+func (writer *jsonWriter) encode(value interface{}) ([]byte, error) {
+	writer.scratch.Reset()
+
+	err := writer.encoder.Encode(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrWriteJSON, err)
+	}
+
+	// encoder always terminates value with a new line
+	return bytes.TrimSuffix(writer.scratch.Bytes(), []byte("\n")), nil
+}
+
+func (writer *jsonWriter) Flush() error {
+	return writer.writer.Flush()
 }
@@ -1,7 +1,10 @@
 package mask
 
 import (
+	"bytes"
 	"errors"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/bradleyjkemp/cupaloy/v2"
@@ -80,15 +83,266 @@ func TestJSONInspector(t *testing.T) {
 func TestJSONInspectorError(t *testing.T) {
 	jsonInspector := NewJSONInspector(NewJSONMask(), &ClassifierMock{})
 
-	input := "{[}]}"
+	for _, input := range []string{"{[}]}", "", `{"a":1}{"b":2}`, `{"a":1`} {
+		output, err := jsonInspector.Inspect([]byte(input))
+
+		if output != nil {
+			t.Fatalf("expected nil output but got one instead")
+		}
+
+		if !errors.Is(err, ErrDecodeJSON) {
+			t.Fatalf("expected to get wrapped error for input %s", input)
+		}
+	}
+}
+
+func TestJSONInspectorStream(t *testing.T) {
+	jsonInspector := NewJSONInspector(NewJSONMask(), NewPIIClassifier(NewDefaultPIIPatterns()))
+
+	input := `{"zeta": 12345678901234567890, "first_name": "mark", "price": 1.50e2, "tags": ["<a>", "name"], "alpha": null}`
+	expected := `{"zeta":12345678901234567890,"first_name":"x","price":1.50e2,"tags":["<a>","name"],"alpha":null}`
+
+	output := bytes.NewBuffer(nil)
+
+	err := jsonInspector.InspectStream(strings.NewReader(input), output)
+	if err != nil {
+		t.Fatalf("expected to stream json got %s instead", err)
+	}
+
+	if output.String() != expected {
+		t.Fatalf("expected key order and number formatting to be preserved, expected %s got %s", expected, output.String())
+	}
+}
+
+func TestJSONRulesInspector(t *testing.T) {
+	jsonInspector := NewJSONRulesInspector([]Rule{
+		{
+			Classifier: NewPIIClassifier([]PIIPattern{
+				&PIIClassifierPattern{
+					Regexp: regexp.MustCompile(`^email$`),
+				},
+			}),
+			Types: []FieldType{FieldTypeString},
+			Mask: &JSONMask{
+				String: &FixedStringMask{Value: "redacted"},
+			},
+		},
+		{
+			Classifier: NewPIIClassifier([]PIIPattern{
+				&PIIClassifierPattern{
+					Regexp: regexp.MustCompile(`\w*e\w*`),
+				},
+			}),
+			Mask: &JSONMask{
+				String:  &FixedStringMask{Value: "?"},
+				Float64: &FixedFloat64Mask{Value: -1},
+				Boolean: &FixedBooleanMask{Value: true},
+			},
+		},
+	})
+
+	input := `{"email": "mark@domain.com", "age": 30, "verified": false, "id": 7}`
+	expected := `{"email":"redacted","age":-1,"verified":true,"id":7}`
+
+	output, err := jsonInspector.Inspect([]byte(input))
+	if err != nil {
+		t.Fatalf("expected to inspect json got %s instead", err)
+	}
+
+	if string(output) != expected {
+		t.Fatalf("expected first matching rule to mask each field, expected %s got %s", expected, output)
+	}
+}
+
+func TestParseFieldType(t *testing.T) {
+	for _, input := range []string{"string", "float64", "bool"} {
+		fieldType, err := ParseFieldType(input)
+		if err != nil || string(fieldType) != input {
+			t.Fatalf("failed to parse field type %s got %s", input, err)
+		}
+	}
+
+	_, err := ParseFieldType("date")
+	if !errors.Is(err, ErrUnknownFieldType) {
+		t.Fatalf("expected ErrUnknownFieldType got %s instead", err)
+	}
+}
+
+func TestJSONInspectorValueClassification(t *testing.T) {
+	jsonInspector := NewJSONRulesInspector([]Rule{
+		{
+			Classifier: NewPIIClassifier(NewDefaultPIIPatterns()),
+			Values:     NewPIIValueClassifier(NewDefaultPIIValuePatterns()),
+			Mask:       NewJSONMask(),
+		},
+	})
+
+	input := `{"contact": "mark@domain.com", "name": "mark", "value": 4111111111111111, "ips": ["10.0.0.1", "local"], "id": 12}`
+	expected := `{"contact":"x","name":"x","value":0,"ips":["x","local"],"id":12}`
 
 	output, err := jsonInspector.Inspect([]byte(input))
+	if err != nil {
+		t.Fatalf("expected to inspect json got %s instead", err)
+	}
+
+	if string(output) != expected {
+		t.Fatalf("expected values to be classified by content, expected %s got %s", expected, output)
+	}
+}
+
+func TestJSONInspectorPaths(t *testing.T) {
+	paths, err := ParsePathPatterns([]string{"$.users[*].email", "$..address.street"})
+	if err != nil {
+		t.Fatalf("failed to parse paths %s", err)
+	}
+
+	exclude, err := ParsePathPatterns([]string{"$.product.name"})
+	if err != nil {
+		t.Fatalf("failed to parse paths %s", err)
+	}
+
+	jsonInspector := NewJSONRulesInspector([]Rule{
+		{
+			Classifier: NewPIIClassifier(NewDefaultPIIPatterns()),
+			Paths:      paths,
+			Exclude:    exclude,
+			Mask:       NewJSONMask(),
+		},
+	})
+
+	input := `{"users": [{"email": "a", "id": 1, "address": {"street": "main"}}], "product": {"name": "shoe"}, "contact": {"email": "b"}}`
+	expected := `{"users":[{"email":"x","id":1,"address":{"street":"x"}}],"product":{"name":"shoe"},"contact":{"email":"x"}}`
+
+	output, err := jsonInspector.Inspect([]byte(input))
+	if err != nil {
+		t.Fatalf("expected to inspect json got %s instead", err)
+	}
+
+	if string(output) != expected {
+		t.Fatalf("expected paths to be included and excluded, expected %s got %s", expected, output)
+	}
+}
+
+func TestJSONInspectorWholeMask(t *testing.T) {
+	fieldRule := func(field string, mask Mask) Rule {
+		return Rule{
+			Classifier: NewPIIClassifier([]PIIPattern{
+				&PIIClassifierPattern{
+					Regexp: regexp.MustCompile(field),
+				},
+			}),
+			Mask: mask,
+		}
+	}
+
+	paths, err := ParsePathPatterns([]string{"$.tags[0]"})
+	if err != nil {
+		t.Fatalf("failed to parse paths %s", err)
+	}
+
+	jsonInspector := NewJSONRulesInspector([]Rule{
+		fieldRule(`^personal_data$`, &DropMask{}),
+		fieldRule(`^address$`, &NullMask{}),
+		fieldRule(`^emails$`, &EmptyMask{}),
+		fieldRule(`^secret$`, &MarkerMask{Marker: "[REDACTED]"}),
+		fieldRule(`name`, NewJSONMask()),
+		{
+			Paths: paths,
+			Mask:  &DropMask{},
+		},
+	})
+
+	testCases := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{
+			testName: "containers",
+			input:    `{"personal_data": {"name": "mark", "age": [1, 2]}, "id": 1, "address": {"street": "main"}, "emails": ["a", "b"], "secret": {"a": 1}, "tags": ["a", "b", "c"]}`,
+			expected: `{"id":1,"address":null,"emails":[],"secret":"[REDACTED]","tags":["b","c"]}`,
+		},
+		{
+			testName: "first_member_dropped",
+			input:    `{"personal_data": "mark", "names": {"name": "mark"}}`,
+			expected: `{"names":{"name":"x"}}`,
+		},
+		{
+			testName: "only_member_dropped",
+			input:    `{"personal_data": [{"a": "b"}]}`,
+			expected: `{}`,
+		},
+		{
+			testName: "scalars",
+			input:    `{"emails": "a@b.com", "secret": 12, "address": true}`,
+			expected: `{"emails":"","secret":"[REDACTED]","address":null}`,
+		},
+	}
+
+	for _, test := range testCases {
+		output, err := jsonInspector.Inspect([]byte(test.input))
+		if err != nil {
+			t.Fatalf("for test %s expected to inspect json got %s instead", test.testName, err)
+		}
+
+		if string(output) != test.expected {
+			t.Fatalf("for test %s expected %s got %s", test.testName, test.expected, output)
+		}
+	}
+}
+
+func TestJSONInspectorNullAndExactNumbers(t *testing.T) {
+	tokenizer, err := NewHMACTokenizer("k1", []byte("secret"), "", 0)
+	if err != nil {
+		t.Fatalf("failed to create tokenizer %s", err)
+	}
 
-	if output != nil {
-		t.Fatalf("expected nil output but got one instead")
+	rules := []Rule{
+		{
+			Classifier: NewPIIClassifier([]PIIPattern{&PIIClassifierPattern{Regexp: regexp.MustCompile(`.*`)}}),
+			Types:      []FieldType{FieldTypeNull},
+			Mask:       &DropMask{},
+		},
+		{
+			Classifier: NewPIIClassifier([]PIIPattern{&PIIClassifierPattern{Regexp: regexp.MustCompile(`^id$`)}}),
+			Mask:       &TokenMask{Tokenizer: tokenizer},
+		},
+		{
+			Classifier: NewPIIClassifier([]PIIPattern{&PIIClassifierPattern{Regexp: regexp.MustCompile(`^balance$`)}}),
+			Mask: &JSONMask{
+				Float64: &Float64Mask{},
+				Number:  &FormatPreservingNumberMask{},
+			},
+		},
 	}
 
-	if !errors.Is(err, ErrDecodeJSON) {
-		t.Fatalf("expected to get wrapped error")
+	input := `{"id": 12345678901234567891, "balance": 98765432109876543210.55, "deleted_at": null}`
+
+	testCases := []struct {
+		testName  string
+		inspector Inspector
+		expected  string
+	}{
+		{
+			testName:  "exact",
+			inspector: NewExactJSONRulesInspector(rules),
+			expected:  `{"id":"` + tokenizer.Tokenize("12345678901234567891") + `","balance":10000000000000000000.00}`,
+		},
+		{
+			testName:  "float64",
+			inspector: NewJSONRulesInspector(rules),
+			expected:  `{"id":"` + tokenizer.Tokenize("12345678901234567000") + `","balance":0}`,
+		},
+	}
+
+	for _, test := range testCases {
+		output, err := test.inspector.Inspect([]byte(input))
+		if err != nil {
+			t.Fatalf("for test %s expected to inspect json got %s instead", test.testName, err)
+		}
+
+		if string(output) != test.expected {
+			t.Fatalf("for test %s expected %s got %s", test.testName, test.expected, output)
+		}
 	}
 }
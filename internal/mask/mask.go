@@ -1,5 +1,15 @@
 package mask
 
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
 type Mask interface {
 	Mask(value interface{}, fieldType FieldType) interface{}
 }
@@ -26,9 +36,37 @@ func (mask *BooleanMask) Mask(input bool) bool {
 	return false
 }
 
+// FixedStringMask replaces every string with Value
+type FixedStringMask struct {
+	Value string
+}
+
+func (mask *FixedStringMask) Mask(input string) string {
+	return mask.Value
+}
+
+type FixedFloat64Mask struct {
+	Value float64
+}
+
+func (mask *FixedFloat64Mask) Mask(input float64) float64 {
+	return mask.Value
+}
+
+type FixedBooleanMask struct {
+	Value bool
+}
+
+func (mask *FixedBooleanMask) Mask(input bool) bool {
+	return mask.Value
+}
+
+// JSONMask masks each type with its own mask. Numbers of exact inspector are masked by Number,
+// when it is nil they are converted to float64 and masked by Float64. Nulls are kept
 type JSONMask struct {
 	String  FieldMask[string]
 	Float64 FieldMask[float64]
+	Number  FieldMask[json.Number]
 	Boolean FieldMask[bool]
 }
 
@@ -45,10 +83,224 @@ func (jsonMask *JSONMask) Mask(input interface{}, fieldType FieldType) interface
 	case FieldTypeString:
 		return jsonMask.String.Mask(input.(string))
 	case FieldTypeFloat64:
-		return jsonMask.Float64.Mask(input.(float64))
+		number, exact := input.(json.Number)
+		if !exact {
+			return jsonMask.Float64.Mask(input.(float64))
+		}
+
+		if jsonMask.Number != nil {
+			return jsonMask.Number.Mask(number)
+		}
+
+		float, err := number.Float64()
+		if err != nil {
+			return jsonMask.Float64.Mask(0)
+		}
+
+		return jsonMask.Float64.Mask(float)
 	case FieldTypeBool:
 		return jsonMask.Boolean.Mask(input.(bool))
 	}
 
 	return input
 }
+
+// KeepLastMask replaces all but last Keep characters with Char, values not longer than Keep are replaced whole
+type KeepLastMask struct {
+	Keep int
+	Char rune
+}
+
+func (mask *KeepLastMask) Mask(input string) string {
+	runes := []rune(input)
+	if len(runes) <= mask.Keep {
+		return strings.Repeat(string(mask.Char), len(runes))
+	}
+
+	return strings.Repeat(string(mask.Char), len(runes)-mask.Keep) + string(runes[len(runes)-mask.Keep:])
+}
+
+// EmailMask keeps first character of local part and the domain so "john@example.com" becomes "j***@example.com",
+// values which aren't emails are replaced whole
+type EmailMask struct {
+	Char rune
+}
+
+func (mask *EmailMask) Mask(input string) string {
+	at := strings.LastIndex(input, "@")
+	if at <= 0 {
+		return strings.Repeat(string(mask.Char), utf8.RuneCountInString(input))
+	}
+
+	first, _ := utf8.DecodeRuneInString(input)
+
+	return string(first) + strings.Repeat(string(mask.Char), 3) + input[at:]
+}
+
+// SubstituteMask replaces every character with Char so value keeps its length
+type SubstituteMask struct {
+	Char rune
+}
+
+func (mask *SubstituteMask) Mask(input string) string {
+	return strings.Repeat(string(mask.Char), utf8.RuneCountInString(input))
+}
+
+// FixedWidthMask replaces value with Width characters so its length isn't revealed
+type FixedWidthMask struct {
+	Width int
+	Char  rune
+}
+
+func (mask *FixedWidthMask) Mask(input string) string {
+	return strings.Repeat(string(mask.Char), mask.Width)
+}
+
+// date layouts FormatPreservingMask recognizes
+var formatPreservingDateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	time.DateOnly,
+	"02/01/2006",
+	"01/02/2006",
+	"02.01.2006",
+}
+
+// FormatPreservingMask replaces dates with unix epoch in the same layout, other values keep their separators while
+// digits are replaced with "0" and letters with "x" or "X" so validators of the format still accept them
+type FormatPreservingMask struct{}
+
+func (mask *FormatPreservingMask) Mask(input string) string {
+	for _, layout := range formatPreservingDateLayouts {
+		_, err := time.Parse(layout, input)
+		if err == nil {
+			return time.Unix(0, 0).UTC().Format(layout)
+		}
+	}
+
+	return strings.Map(func(char rune) rune {
+		switch {
+		case unicode.IsDigit(char):
+			return '0'
+		case unicode.IsUpper(char):
+			return 'X'
+		case unicode.IsLetter(char):
+			return 'x'
+		}
+
+		return char
+	}, input)
+}
+
+// FormatPreservingFloat64Mask replaces number with the smallest number that has the same count of integer digits and sign,
+// so value keeps its order of magnitude
+type FormatPreservingFloat64Mask struct{}
+
+func (mask *FormatPreservingFloat64Mask) Mask(input float64) float64 {
+	if math.Abs(input) < 1 || math.IsInf(input, 0) || math.IsNaN(input) {
+		return 0
+	}
+
+	// counting digits of formatted value avoids rounding errors of math.Log10
+	digits := len(strconv.FormatFloat(math.Trunc(math.Abs(input)), 'f', 0, 64))
+
+	return math.Copysign(math.Pow(10, float64(digits-1)), input)
+}
+
+// FormatPreservingNumberMask replaces every digit of number literal but the first one with "0" and the first one with "1",
+// so number keeps its sign, count of digits and decimal places however large it is
+type FormatPreservingNumberMask struct{}
+
+func (mask *FormatPreservingNumberMask) Mask(input json.Number) json.Number {
+	output := []byte(input.String())
+	first := true
+
+	for i, char := range output {
+		// digits of exponent are kept so number keeps its order of magnitude
+		if char == 'e' || char == 'E' {
+			break
+		}
+
+		if char < '0' || char > '9' {
+			continue
+		}
+
+		output[i] = '0'
+		if first && char != '0' {
+			output[i] = '1'
+			first = false
+		}
+	}
+
+	return json.Number(output)
+}
+
+// WholeMask masks value of any type as a unit, objects and arrays included, instead of looking inside it.
+// Dropped object members and array elements are removed, dropped top level value becomes null
+type WholeMask interface {
+	Mask
+	MaskWhole(fieldType FieldType) (replacement interface{}, drop bool)
+}
+
+// DropMask removes the key and its value
+type DropMask struct{}
+
+func (mask *DropMask) Mask(input interface{}, fieldType FieldType) interface{} {
+	return nil
+}
+
+func (mask *DropMask) MaskWhole(fieldType FieldType) (interface{}, bool) {
+	return nil, true
+}
+
+// NullMask replaces value with null
+type NullMask struct{}
+
+func (mask *NullMask) Mask(input interface{}, fieldType FieldType) interface{} {
+	return nil
+}
+
+func (mask *NullMask) MaskWhole(fieldType FieldType) (interface{}, bool) {
+	return nil, false
+}
+
+// EmptyMask replaces value with empty value of its type, {} for objects, [] for arrays, "" for strings, 0 and false
+type EmptyMask struct{}
+
+func (mask *EmptyMask) Mask(input interface{}, fieldType FieldType) interface{} {
+	replacement, _ := mask.MaskWhole(fieldType)
+
+	return replacement
+}
+
+func (mask *EmptyMask) MaskWhole(fieldType FieldType) (interface{}, bool) {
+	switch fieldType {
+	case FieldTypeObject:
+		return struct{}{}, false
+	case FieldTypeArray:
+		return []interface{}{}, false
+	case FieldTypeString:
+		return "", false
+	case FieldTypeFloat64:
+		return 0, false
+	case FieldTypeBool:
+		return false, false
+	}
+
+	return nil, false
+}
+
+// MarkerMask collapses value to redaction Marker string
+type MarkerMask struct {
+	Marker string
+}
+
+func (mask *MarkerMask) Mask(input interface{}, fieldType FieldType) interface{} {
+	return mask.Marker
+}
+
+func (mask *MarkerMask) MaskWhole(fieldType FieldType) (interface{}, bool) {
+	return mask.Marker, false
+}
@@ -0,0 +1,121 @@
+package mask_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/vjerci/reverse-proxy/internal/mask"
+)
+
+func TestStringMasks(t *testing.T) {
+	testCases := []struct {
+		testName string
+		mask     mask.FieldMask[string]
+		input    string
+		expected string
+	}{
+		{
+			testName: "keep_last",
+			mask:     &mask.KeepLastMask{Keep: 4, Char: '*'},
+			input:    "4111111111111234",
+			expected: "************1234",
+		},
+		{
+			testName: "keep_last_short",
+			mask:     &mask.KeepLastMask{Keep: 4, Char: '*'},
+			input:    "123",
+			expected: "***",
+		},
+		{
+			testName: "email",
+			mask:     &mask.EmailMask{Char: '*'},
+			input:    "john.doe@example.com",
+			expected: "j***@example.com",
+		},
+		{
+			testName: "email_not_email",
+			mask:     &mask.EmailMask{Char: '*'},
+			input:    "john",
+			expected: "****",
+		},
+		{
+			testName: "substitute",
+			mask:     &mask.SubstituteMask{Char: '#'},
+			input:    "žana",
+			expected: "####",
+		},
+		{
+			testName: "fixed_width",
+			mask:     &mask.FixedWidthMask{Width: 8, Char: '*'},
+			input:    "secret",
+			expected: "********",
+		},
+		{
+			testName: "format_preserving_date",
+			mask:     &mask.FormatPreservingMask{},
+			input:    "1990-05-17",
+			expected: "1970-01-01",
+		},
+		{
+			testName: "format_preserving_timestamp",
+			mask:     &mask.FormatPreservingMask{},
+			input:    "1990-05-17T10:20:30Z",
+			expected: "1970-01-01T00:00:00Z",
+		},
+		{
+			testName: "format_preserving_text",
+			mask:     &mask.FormatPreservingMask{},
+			input:    "AB-12 cd",
+			expected: "XX-00 xx",
+		},
+	}
+
+	for _, test := range testCases {
+		output := test.mask.Mask(test.input)
+
+		if output != test.expected {
+			t.Fatalf("for test %s expected %s got %s", test.testName, test.expected, output)
+		}
+	}
+}
+
+func TestFormatPreservingFloat64Mask(t *testing.T) {
+	testCases := []struct {
+		input    float64
+		expected float64
+	}{
+		{input: 1000, expected: 1000},
+		{input: 4321.5, expected: 1000},
+		{input: -57, expected: -10},
+		{input: 0.25, expected: 0},
+	}
+
+	for _, test := range testCases {
+		output := (&mask.FormatPreservingFloat64Mask{}).Mask(test.input)
+
+		if output != test.expected {
+			t.Fatalf("for input %f expected %f got %f", test.input, test.expected, output)
+		}
+	}
+}
+
+func TestFormatPreservingNumberMask(t *testing.T) {
+	testCases := []struct {
+		input    json.Number
+		expected json.Number
+	}{
+		{input: "12345678901234567891", expected: "10000000000000000000"},
+		{input: "-57.25", expected: "-10.00"},
+		{input: "0.0045", expected: "0.0010"},
+		{input: "1.5e10", expected: "1.0e10"},
+		{input: "0", expected: "0"},
+	}
+
+	for _, test := range testCases {
+		output := (&mask.FormatPreservingNumberMask{}).Mask(test.input)
+
+		if output != test.expected {
+			t.Fatalf("for input %s expected %s got %s", test.input, test.expected, output)
+		}
+	}
+}
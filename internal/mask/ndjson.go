@@ -0,0 +1,72 @@
+package mask
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// NDJSONInspector masks every line of newline delimited json as a separate document and writes it out as soon as it is masked,
+// so clients get each line without waiting for the upstream to finish. Blank lines and line endings are kept
+type NDJSONInspector struct {
+	lineInspector Inspector
+}
+
+// NewNDJSONInspector masks lines with lineInspector, which is usually json inspector sharing rules with other inspectors
+func NewNDJSONInspector(lineInspector Inspector) StreamInspector {
+	return &NDJSONInspector{
+		lineInspector: lineInspector,
+	}
+}
+
+func (inspector *NDJSONInspector) Inspect(input []byte) ([]byte, error) {
+	buff := bytes.NewBuffer(nil)
+
+	err := inspector.InspectStream(bytes.NewReader(input), buff)
+	if err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
+
+func (inspector *NDJSONInspector) InspectStream(input io.Reader, output io.Writer) error {
+	reader := bufio.NewReader(input)
+
+	for lineNumber := 1; ; lineNumber++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("%w: %w", ErrDecodeJSON, readErr)
+		}
+
+		content, ending := splitLineEnding(line)
+
+		if len(bytes.TrimSpace(content)) > 0 {
+			masked, err := inspector.lineInspector.Inspect(content)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+
+			content = masked
+		}
+
+		// each line is written on its own so it reaches the client right away
+		_, err := output.Write(append(content, ending...))
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrWriteJSON, err)
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+// splitLineEnding splits "\n" or "\r\n" off the end of line
+func splitLineEnding(line []byte) (content []byte, ending []byte) {
+	content = bytes.TrimSuffix(line, []byte("\n"))
+	content = bytes.TrimSuffix(content, []byte("\r"))
+
+	return content, line[len(content):]
+}
@@ -0,0 +1,87 @@
+package mask
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestNDJSONInspector(t *testing.T) {
+	ndjsonInspector := NewNDJSONInspector(NewJSONInspector(NewJSONMask(), NewPIIClassifier(NewDefaultPIIPatterns())))
+
+	testCases := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{
+			testName: "lines",
+			input:    "{\"name\": \"mark\", \"id\": 1}\n{\"name\": \"john\"}\n",
+			expected: "{\"name\":\"x\",\"id\":1}\n{\"name\":\"x\"}\n",
+		},
+		{
+			testName: "blank_lines_and_crlf",
+			input:    "{\"name\": \"mark\"}\r\n\r\n  \n[\"name\"]",
+			expected: "{\"name\":\"x\"}\r\n\r\n  \n[\"name\"]",
+		},
+		{
+			testName: "empty",
+			input:    "",
+			expected: "",
+		},
+	}
+
+	for _, test := range testCases {
+		output, err := ndjsonInspector.Inspect([]byte(test.input))
+		if err != nil {
+			t.Fatalf("for test %s expected to inspect ndjson got %s instead", test.testName, err)
+		}
+
+		if string(output) != test.expected {
+			t.Fatalf("for test %s expected %q got %q", test.testName, test.expected, output)
+		}
+	}
+
+	_, err := ndjsonInspector.Inspect([]byte("{\"name\": \"mark\"}\n{\"name\": [}\n"))
+	if !errors.Is(err, ErrDecodeJSON) {
+		t.Fatalf("expected ErrDecodeJSON for invalid line got %s instead", err)
+	}
+}
+
+func TestNDJSONInspectorStream(t *testing.T) {
+	ndjsonInspector := NewNDJSONInspector(NewJSONInspector(NewJSONMask(), NewPIIClassifier(NewDefaultPIIPatterns())))
+
+	inputReader, inputWriter := io.Pipe()
+	outputReader, outputWriter := io.Pipe()
+
+	go func() {
+		outputWriter.CloseWithError(ndjsonInspector.InspectStream(inputReader, outputWriter))
+	}()
+
+	output := bufio.NewReader(outputReader)
+
+	for _, line := range []string{"{\"name\": \"mark\"}\n", "{\"name\": \"john\"}\n"} {
+		_, err := inputWriter.Write([]byte(line))
+		if err != nil {
+			t.Fatalf("failed to write line %s", err)
+		}
+
+		// line is masked while upstream still hasn't finished
+		masked, err := output.ReadString('\n')
+		if err != nil {
+			t.Fatalf("expected masked line before end of input got %s instead", err)
+		}
+
+		if masked != "{\"name\":\"x\"}\n" {
+			t.Fatalf("expected masked line got %q instead", masked)
+		}
+	}
+
+	inputWriter.Close()
+
+	_, err := output.ReadString('\n')
+	if err != io.EOF {
+		t.Fatalf("expected end of output got %s instead", err)
+	}
+}
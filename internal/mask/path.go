@@ -0,0 +1,250 @@
+package mask
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidPath = errors.New("invalid json path")
+
+// PathSegment is an object key or an array index of a json path, xml elements are keys and xml attributes are keys with Attribute set
+type PathSegment struct {
+	Key       string
+	Index     int
+	IsIndex   bool
+	Attribute bool
+}
+
+// Path locates value within document, top level value has empty path
+type Path []PathSegment
+
+// String formats path like "$.users[0].email"
+func (path Path) String() string {
+	builder := strings.Builder{}
+	builder.WriteString("$")
+
+	for _, segment := range path {
+		switch {
+		case segment.IsIndex:
+			builder.WriteString("[" + strconv.Itoa(segment.Index) + "]")
+		case segment.Attribute:
+			builder.WriteString(".@" + segment.Key)
+		case isPathName(segment.Key):
+			builder.WriteString("." + segment.Key)
+		default:
+			builder.WriteString("['" + strings.ReplaceAll(segment.Key, "'", "\\'") + "']")
+		}
+	}
+
+	return builder.String()
+}
+
+// name of the field, values of array elements and top level value have none
+func (path Path) fieldName() (string, bool) {
+	if len(path) == 0 || path[len(path)-1].IsIndex {
+		return "", false
+	}
+
+	return path[len(path)-1].Key, true
+}
+
+// PathClassifier classifies field by its full path within document
+type PathClassifier interface {
+	ClassifyPath(path Path, fieldType FieldType) (isClassified bool)
+}
+
+type pathStepKind int
+
+const (
+	pathStepKey pathStepKind = iota
+	pathStepIndex
+	pathStepWildcard
+)
+
+type pathStep struct {
+	kind pathStepKind
+	// recursive step matches at any depth below previous step like ".."
+	recursive bool
+	attribute bool
+	key       string
+	index     int
+}
+
+func (step *pathStep) matches(segment PathSegment) bool {
+	switch step.kind {
+	case pathStepKey:
+		return !segment.IsIndex && segment.Attribute == step.attribute && segment.Key == step.key
+	case pathStepIndex:
+		return segment.IsIndex && segment.Index == step.index
+	}
+
+	return true
+}
+
+// PathPattern is a JSONPath expression supporting child ".name", "['name']", index "[0]", wildcard ".*" or "[*]"
+// and recursive descent "..name". xml attributes are matched by ".@name"
+type PathPattern struct {
+	expression string
+	steps      []pathStep
+}
+
+func ParsePathPattern(expression string) (*PathPattern, error) {
+	if !strings.HasPrefix(expression, "$") {
+		return nil, fmt.Errorf("%w: %s must start with $", ErrInvalidPath, expression)
+	}
+
+	steps := []pathStep{}
+
+	for rest := expression[1:]; rest != ""; {
+		var step pathStep
+		var err error
+
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			step, rest, err = parsePathChild(rest[2:])
+			step.recursive = true
+		case strings.HasPrefix(rest, "."):
+			step, rest, err = parsePathChild(rest[1:])
+		case strings.HasPrefix(rest, "["):
+			step, rest, err = parsePathBracket(rest)
+		default:
+			err = fmt.Errorf("unexpected %q", rest)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidPath, expression, err)
+		}
+
+		steps = append(steps, step)
+	}
+
+	return &PathPattern{
+		expression: expression,
+		steps:      steps,
+	}, nil
+}
+
+// parsePathChild parses name or wildcard following "." or "..", bracket may follow ".." directly
+func parsePathChild(rest string) (pathStep, string, error) {
+	if strings.HasPrefix(rest, "[") {
+		return parsePathBracket(rest)
+	}
+
+	end := strings.IndexAny(rest, ".[]")
+	if end == -1 {
+		end = len(rest)
+	}
+
+	name := rest[:end]
+	if name == "" {
+		return pathStep{}, "", errors.New("empty name")
+	}
+
+	if name == "*" {
+		return pathStep{kind: pathStepWildcard}, rest[end:], nil
+	}
+
+	// dot form ".@name" addresses xml attribute "name" of the element
+	if strings.HasPrefix(name, "@") {
+		return pathStep{kind: pathStepKey, key: name[1:], attribute: true}, rest[end:], nil
+	}
+
+	return pathStep{kind: pathStepKey, key: name}, rest[end:], nil
+}
+
+func parsePathBracket(rest string) (pathStep, string, error) {
+	end := strings.Index(rest, "]")
+	if end == -1 {
+		return pathStep{}, "", errors.New("unclosed [")
+	}
+
+	content := rest[1:end]
+	rest = rest[end+1:]
+
+	if content == "*" {
+		return pathStep{kind: pathStepWildcard}, rest, nil
+	}
+
+	if len(content) >= 2 && (content[0] == '\'' || content[0] == '"') && content[len(content)-1] == content[0] {
+		return pathStep{kind: pathStepKey, key: content[1 : len(content)-1]}, rest, nil
+	}
+
+	index, err := strconv.Atoi(content)
+	if err != nil || index < 0 {
+		return pathStep{}, "", fmt.Errorf("invalid index %q", content)
+	}
+
+	return pathStep{kind: pathStepIndex, index: index}, rest, nil
+}
+
+func (pattern *PathPattern) String() string {
+	return pattern.expression
+}
+
+func (pattern *PathPattern) Match(path Path) bool {
+	return matchPathSteps(pattern.steps, path)
+}
+
+func matchPathSteps(steps []pathStep, path Path) bool {
+	if len(steps) == 0 {
+		return len(path) == 0
+	}
+
+	step := steps[0]
+
+	if !step.recursive {
+		return len(path) > 0 && step.matches(path[0]) && matchPathSteps(steps[1:], path[1:])
+	}
+
+	for i := range path {
+		if step.matches(path[i]) && matchPathSteps(steps[1:], path[i+1:]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PathPatterns classifies paths matching any of its patterns
+type PathPatterns []*PathPattern
+
+func ParsePathPatterns(expressions []string) (PathPatterns, error) {
+	patterns := make(PathPatterns, 0, len(expressions))
+
+	for _, expression := range expressions {
+		pattern, err := ParsePathPattern(expression)
+		if err != nil {
+			return nil, err
+		}
+
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns, nil
+}
+
+func (patterns PathPatterns) ClassifyPath(path Path, fieldType FieldType) bool {
+	for _, pattern := range patterns {
+		if pattern.Match(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isPathName(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	for _, char := range key {
+		if !(char == '_' || char == '-' || char >= '0' && char <= '9' || char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,65 @@
+package mask_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vjerci/reverse-proxy/internal/mask"
+)
+
+func key(name string) mask.PathSegment {
+	return mask.PathSegment{Key: name}
+}
+
+func index(i int) mask.PathSegment {
+	return mask.PathSegment{Index: i, IsIndex: true}
+}
+
+func TestPathPatternMatch(t *testing.T) {
+	testCases := []struct {
+		expression     string
+		path           mask.Path
+		expectedResult bool
+	}{
+		{expression: "$.users[*].email", path: mask.Path{key("users"), index(3), key("email")}, expectedResult: true},
+		{expression: "$.users[*].email", path: mask.Path{key("users"), key("email")}, expectedResult: false},
+		{expression: "$.users[1].email", path: mask.Path{key("users"), index(3), key("email")}, expectedResult: false},
+		{expression: "$..address.street", path: mask.Path{key("user"), key("address"), key("street")}, expectedResult: true},
+		{expression: "$..address.street", path: mask.Path{key("address"), key("street")}, expectedResult: true},
+		{expression: "$..address.street", path: mask.Path{key("address"), key("city")}, expectedResult: false},
+		{expression: "$..name", path: mask.Path{key("products"), index(0), key("name")}, expectedResult: true},
+		{expression: "$.product.name", path: mask.Path{key("user"), key("name")}, expectedResult: false},
+		{expression: "$['first name']", path: mask.Path{key("first name")}, expectedResult: true},
+		{expression: "$.*.id", path: mask.Path{key("user"), key("id")}, expectedResult: true},
+		{expression: "$..[*]", path: mask.Path{key("tags"), index(0)}, expectedResult: true},
+		{expression: "$", path: mask.Path{}, expectedResult: true},
+	}
+
+	for _, test := range testCases {
+		pattern, err := mask.ParsePathPattern(test.expression)
+		if err != nil {
+			t.Fatalf("failed to parse %s got %s", test.expression, err)
+		}
+
+		if pattern.Match(test.path) != test.expectedResult {
+			t.Fatalf("for pattern %s and path %s expected match %t", test.expression, test.path, test.expectedResult)
+		}
+	}
+}
+
+func TestParsePathPatternError(t *testing.T) {
+	for _, expression := range []string{"users.email", "$.", "$[", "$[-1]", "$.users]"} {
+		_, err := mask.ParsePathPattern(expression)
+		if !errors.Is(err, mask.ErrInvalidPath) {
+			t.Fatalf("expected ErrInvalidPath for %s got %s instead", expression, err)
+		}
+	}
+}
+
+func TestPathString(t *testing.T) {
+	path := mask.Path{key("users"), index(0), key("first name")}
+
+	if path.String() != "$.users[0]['first name']" {
+		t.Fatalf("unexpected path format %s", path.String())
+	}
+}
@@ -0,0 +1,155 @@
+package mask
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+var ErrUnknownContentPolicy = errors.New("unknown content policy")
+var ErrInvalidMediaType = errors.New("invalid media type")
+
+// UnknownContentPolicy tells what happens to body which should be masked but has no inspector for its content type
+type UnknownContentPolicy string
+
+// UnknownContentPass forwards body unmasked
+const UnknownContentPass = UnknownContentPolicy("pass")
+
+// UnknownContentBlock refuses to forward body
+const UnknownContentBlock = UnknownContentPolicy("block")
+
+// UnknownContentLog forwards body unmasked and logs that it did so
+const UnknownContentLog = UnknownContentPolicy("log")
+
+func ParseUnknownContentPolicy(policy string) (UnknownContentPolicy, error) {
+	switch UnknownContentPolicy(policy) {
+	case UnknownContentPass, UnknownContentBlock, UnknownContentLog:
+		return UnknownContentPolicy(policy), nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnknownContentPolicy, policy)
+}
+
+type registryEntry struct {
+	params    map[string]string
+	inspector Inspector
+}
+
+// Registry picks inspector by content type of body.
+// Inspector registered with parameters, like "application/json; profile=audit", is used only for content types carrying
+// the same parameters and wins over inspector registered without them. Parameters not registered, like charset, are ignored.
+// Content type with structured syntax suffix, like "application/problem+json", falls back to inspector of "application/json"
+type Registry struct {
+	entries map[string][]registryEntry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		entries: make(map[string][]registryEntry),
+	}
+}
+
+// NewRegistryFrom registers every inspector under content type it is keyed by
+func NewRegistryFrom(inspectors map[string]Inspector) (*Registry, error) {
+	registry := NewRegistry()
+
+	for contentType, inspector := range inspectors {
+		err := registry.Register(contentType, inspector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return registry, nil
+}
+
+func (registry *Registry) Register(contentType string, inspector Inspector) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidMediaType, contentType, err)
+	}
+
+	registry.entries[mediaType] = append(registry.entries[mediaType], registryEntry{
+		params:    params,
+		inspector: inspector,
+	})
+
+	return nil
+}
+
+func (registry *Registry) Lookup(contentType string) (Inspector, bool) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// content type with malformed parameters is matched by its media type only
+		mediaType, _, _ = strings.Cut(contentType, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		params = nil
+	}
+
+	inspector, ok := registry.lookup(mediaType, params)
+	if !ok {
+		suffix := structuredSyntaxSuffix(mediaType)
+		if suffix == "" {
+			return nil, false
+		}
+
+		inspector, ok = registry.lookup("application/"+suffix, params)
+		if !ok {
+			return nil, false
+		}
+	}
+
+	contentTypeInspector, ok := inspector.(ContentTypeInspector)
+	if ok {
+		return contentTypeInspector.ForContentType(contentType), true
+	}
+
+	return inspector, true
+}
+
+// lookup returns inspector registered with the most parameters all of which content type carries
+func (registry *Registry) lookup(mediaType string, params map[string]string) (Inspector, bool) {
+	var match *registryEntry
+
+	for i, entry := range registry.entries[mediaType] {
+		if !hasParams(params, entry.params) {
+			continue
+		}
+
+		if match == nil || len(entry.params) > len(match.params) {
+			match = &registry.entries[mediaType][i]
+		}
+	}
+
+	if match == nil {
+		return nil, false
+	}
+
+	return match.inspector, true
+}
+
+func hasParams(params map[string]string, required map[string]string) bool {
+	for name, value := range required {
+		if !strings.EqualFold(params[name], value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// structuredSyntaxSuffix returns "json" for "application/vnd.api+json", suffixes are defined by RFC 6839
+func structuredSyntaxSuffix(mediaType string) string {
+	_, subtype, ok := strings.Cut(mediaType, "/")
+	if !ok {
+		return ""
+	}
+
+	index := strings.LastIndex(subtype, "+")
+	if index == -1 {
+		return ""
+	}
+
+	return subtype[index+1:]
+}
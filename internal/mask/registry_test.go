@@ -0,0 +1,93 @@
+package mask_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vjerci/reverse-proxy/internal/mask"
+)
+
+func TestRegistry(t *testing.T) {
+	jsonInspector := mask.NewJSONInspector(mask.NewJSONMask(), mask.NewPIIClassifier(mask.NewDefaultPIIPatterns()))
+	auditInspector := mask.NewJSONInspector(mask.NewJSONMask(), mask.NewPIIClassifier(mask.NewDefaultPIIPatterns()))
+	xmlInspector := mask.NewXMLInspector(mask.NewJSONMask(), mask.NewPIIClassifier(mask.NewDefaultPIIPatterns()))
+
+	registry, err := mask.NewRegistryFrom(map[string]mask.Inspector{
+		"application/json":                jsonInspector,
+		"Application/JSON; Profile=audit": auditInspector,
+		"application/xml":                 xmlInspector,
+	})
+	if err != nil {
+		t.Fatalf("failed to create registry %s", err)
+	}
+
+	testCases := []struct {
+		contentType string
+		expected    mask.Inspector
+	}{
+		{contentType: "application/json", expected: jsonInspector},
+		{contentType: "application/json; charset=utf-8", expected: jsonInspector},
+		{contentType: "APPLICATION/JSON", expected: jsonInspector},
+		{contentType: `application/json; charset="utf-8"; profile=AUDIT`, expected: auditInspector},
+		{contentType: "application/json; profile=other", expected: jsonInspector},
+		{contentType: "application/problem+json", expected: jsonInspector},
+		{contentType: "application/vnd.api+json; profile=audit", expected: auditInspector},
+		{contentType: "application/soap+xml; charset=utf-8", expected: xmlInspector},
+		{contentType: "application/json; charset", expected: jsonInspector},
+		{contentType: "text/plain", expected: nil},
+		{contentType: "application/vnd.custom+yaml", expected: nil},
+		{contentType: "", expected: nil},
+	}
+
+	for _, test := range testCases {
+		inspector, ok := registry.Lookup(test.contentType)
+
+		if ok != (test.expected != nil) || inspector != test.expected {
+			t.Fatalf("for content type %q got unexpected inspector", test.contentType)
+		}
+	}
+
+	_, err = mask.NewRegistryFrom(map[string]mask.Inspector{"application/": jsonInspector})
+	if !errors.Is(err, mask.ErrInvalidMediaType) {
+		t.Fatalf("expected ErrInvalidMediaType got %s instead", err)
+	}
+}
+
+func TestRegistryContentTypeInspector(t *testing.T) {
+	registry, err := mask.NewRegistryFrom(map[string]mask.Inspector{
+		"multipart/form-data": mask.NewMultipartRulesInspector(nil, false),
+	})
+	if err != nil {
+		t.Fatalf("failed to create registry %s", err)
+	}
+
+	input := []byte("--XYZ\r\nContent-Disposition: form-data; name=\"username\"\r\n\r\nmark\r\n--XYZ--\r\n")
+
+	inspector, ok := registry.Lookup("multipart/form-data; boundary=XYZ")
+	if !ok {
+		t.Fatalf("expected to find multipart inspector")
+	}
+
+	output, err := inspector.Inspect(input)
+	if err != nil {
+		t.Fatalf("expected inspector bound to boundary of content type got %s instead", err)
+	}
+
+	if string(output) != string(input) {
+		t.Fatalf("expected %q got %q", input, output)
+	}
+}
+
+func TestParseUnknownContentPolicy(t *testing.T) {
+	for _, input := range []string{"pass", "block", "log"} {
+		policy, err := mask.ParseUnknownContentPolicy(input)
+		if err != nil || string(policy) != input {
+			t.Fatalf("failed to parse unknown content policy %s got %s", input, err)
+		}
+	}
+
+	_, err := mask.ParseUnknownContentPolicy("drop")
+	if !errors.Is(err, mask.ErrUnknownContentPolicy) {
+		t.Fatalf("expected ErrUnknownContentPolicy got %s instead", err)
+	}
+}
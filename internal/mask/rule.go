@@ -0,0 +1,81 @@
+package mask
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownFieldType = errors.New("unknown field type")
+
+// Rule masks fields its Classifier matches by name, its Values matches by value or its Paths matches by path with its own Mask.
+// Fields Exclude matches are never masked by the rule. Nil classifiers are skipped and empty Types matches fields of any type
+type Rule struct {
+	Classifier Classifier
+	Values     ValueClassifier
+	Paths      PathClassifier
+	Exclude    PathClassifier
+	Types      []FieldType
+	Mask       Mask
+}
+
+// values without field name, like array elements, can be matched only by value or path
+func (rule *Rule) matches(path Path, value string, fieldType FieldType) bool {
+	if len(rule.Types) > 0 && !containsFieldType(rule.Types, fieldType) {
+		return false
+	}
+
+	if rule.Exclude != nil && rule.Exclude.ClassifyPath(path, fieldType) {
+		return false
+	}
+
+	fieldName, hasName := path.fieldName()
+	if hasName && rule.Classifier != nil && rule.Classifier.ClassifyField(fieldName, fieldType) {
+		return true
+	}
+
+	if rule.Paths != nil && rule.Paths.ClassifyPath(path, fieldType) {
+		return true
+	}
+
+	return rule.Values != nil && rule.Values.ClassifyValue(value, fieldType)
+}
+
+// firstMatchingRule returns first rule matching the field or nil when field isn't classified.
+// objects and arrays are matched only by rules which mask them as a whole, others look inside them
+func firstMatchingRule(rules []Rule, path Path, value string, fieldType FieldType) *Rule {
+	container := fieldType == FieldTypeObject || fieldType == FieldTypeArray
+
+	for i := range rules {
+		if container {
+			_, ok := rules[i].Mask.(WholeMask)
+			if !ok {
+				continue
+			}
+		}
+
+		if rules[i].matches(path, value, fieldType) {
+			return &rules[i]
+		}
+	}
+
+	return nil
+}
+
+func containsFieldType(fieldTypes []FieldType, fieldType FieldType) bool {
+	for _, candidate := range fieldTypes {
+		if candidate == fieldType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ParseFieldType(fieldType string) (FieldType, error) {
+	switch FieldType(fieldType) {
+	case FieldTypeString, FieldTypeFloat64, FieldTypeBool, FieldTypeObject, FieldTypeArray, FieldTypeNull:
+		return FieldType(fieldType), nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnknownFieldType, fieldType)
+}
@@ -0,0 +1,172 @@
+package mask
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrUnknownKeyID = errors.New("unknown key id")
+var ErrInvalidKeyID = errors.New("key id can't be empty or contain \".\"")
+var ErrInvalidToken = errors.New("invalid token")
+
+// Tokenizer replaces value with a token which is the same every time for the same value and key
+type Tokenizer interface {
+	Tokenize(value string) string
+}
+
+// TokenMask masks values of any type with tokens of its Tokenizer, masked numbers and booleans become strings
+type TokenMask struct {
+	Tokenizer Tokenizer
+}
+
+func (mask *TokenMask) Mask(input interface{}, fieldType FieldType) interface{} {
+	switch value := input.(type) {
+	case string:
+		return mask.Tokenizer.Tokenize(value)
+	case float64:
+		return mask.Tokenizer.Tokenize(strconv.FormatFloat(value, 'f', -1, 64))
+	case json.Number:
+		return mask.Tokenizer.Tokenize(value.String())
+	case bool:
+		return mask.Tokenizer.Tokenize(strconv.FormatBool(value))
+	}
+
+	return input
+}
+
+// HMACTokenizer replaces value with HMAC-SHA256 of it formatted as prefix, key id, "." and hex digest truncated to length,
+// key id lets consumers know which tokens can be joined after key rotation
+type HMACTokenizer struct {
+	keyID  string
+	secret []byte
+	prefix string
+	length int
+}
+
+// length <= 0 keeps whole digest
+func NewHMACTokenizer(keyID string, secret []byte, prefix string, length int) (*HMACTokenizer, error) {
+	if !validKeyID(keyID) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKeyID, keyID)
+	}
+
+	return &HMACTokenizer{
+		keyID:  keyID,
+		secret: secret,
+		prefix: prefix,
+		length: length,
+	}, nil
+}
+
+func (tokenizer *HMACTokenizer) Tokenize(value string) string {
+	digest := hex.EncodeToString(keyedHash(tokenizer.secret, []byte(value)))
+	if tokenizer.length > 0 && tokenizer.length < len(digest) {
+		digest = digest[:tokenizer.length]
+	}
+
+	return tokenizer.prefix + tokenizer.keyID + "." + digest
+}
+
+// AEADTokenizer encrypts values with AES-GCM key of activeKeyID so they can be revealed by Detokenize,
+// all keys of a keyring can reveal tokens so old keys are kept around after rotation.
+// nonce is derived from the value like in SIV mode, so the same value always gets the same token and tokens can be joined
+type AEADTokenizer struct {
+	activeKeyID string
+	keys        map[string]aeadKey
+	prefix      string
+}
+
+type aeadKey struct {
+	aead     cipher.AEAD
+	nonceKey []byte
+}
+
+// keys must be 16, 24 or 32 bytes long
+func NewAEADTokenizer(keys map[string][]byte, activeKeyID string, prefix string) (*AEADTokenizer, error) {
+	if _, ok := keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKeyID, activeKeyID)
+	}
+
+	aeadKeys := make(map[string]aeadKey, len(keys))
+
+	for keyID, secret := range keys {
+		if !validKeyID(keyID) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidKeyID, keyID)
+		}
+
+		block, err := aes.NewCipher(secret)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", keyID, err)
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", keyID, err)
+		}
+
+		aeadKeys[keyID] = aeadKey{
+			aead:     aead,
+			nonceKey: keyedHash(secret, []byte("nonce")),
+		}
+	}
+
+	return &AEADTokenizer{
+		activeKeyID: activeKeyID,
+		keys:        aeadKeys,
+		prefix:      prefix,
+	}, nil
+}
+
+func (tokenizer *AEADTokenizer) Tokenize(value string) string {
+	key := tokenizer.keys[tokenizer.activeKeyID]
+
+	nonce := keyedHash(key.nonceKey, []byte(value))[:key.aead.NonceSize()]
+	// key id is authenticated so token can't be moved to another key
+	sealed := key.aead.Seal(nonce, nonce, []byte(value), []byte(tokenizer.activeKeyID))
+
+	return tokenizer.prefix + tokenizer.activeKeyID + "." + base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+// Detokenize reveals value of token made by any key of the keyring
+func (tokenizer *AEADTokenizer) Detokenize(token string) (string, error) {
+	keyID, payload, ok := strings.Cut(strings.TrimPrefix(token, tokenizer.prefix), ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+
+	key, ok := tokenizer.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKeyID, keyID)
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < key.aead.NonceSize() {
+		return "", ErrInvalidToken
+	}
+
+	value, err := key.aead.Open(nil, sealed[:key.aead.NonceSize()], sealed[key.aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	return string(value), nil
+}
+
+func keyedHash(secret []byte, value []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(value)
+
+	return mac.Sum(nil)
+}
+
+func validKeyID(keyID string) bool {
+	return keyID != "" && !strings.Contains(keyID, ".")
+}
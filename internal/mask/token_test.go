@@ -0,0 +1,116 @@
+package mask_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vjerci/reverse-proxy/internal/mask"
+)
+
+func TestHMACTokenizer(t *testing.T) {
+	tokenizer, err := mask.NewHMACTokenizer("k1", []byte("secret"), "tok_", 16)
+	if err != nil {
+		t.Fatalf("expected to create tokenizer got %s instead", err)
+	}
+
+	token := tokenizer.Tokenize("mark@domain.com")
+
+	if !strings.HasPrefix(token, "tok_k1.") || len(token) != len("tok_k1.")+16 {
+		t.Fatalf("expected token with prefix, key id and truncated digest got %s", token)
+	}
+
+	if tokenizer.Tokenize("mark@domain.com") != token {
+		t.Fatalf("expected token to be stable for the same value")
+	}
+
+	if tokenizer.Tokenize("john@domain.com") == token {
+		t.Fatalf("expected different values to get different tokens")
+	}
+
+	rotated, err := mask.NewHMACTokenizer("k2", []byte("other secret"), "tok_", 16)
+	if err != nil {
+		t.Fatalf("expected to create tokenizer got %s instead", err)
+	}
+
+	if rotated.Tokenize("mark@domain.com")[len("tok_k2."):] == token[len("tok_k1."):] {
+		t.Fatalf("expected different key to produce different digest")
+	}
+
+	_, err = mask.NewHMACTokenizer("k.1", []byte("secret"), "", 0)
+	if !errors.Is(err, mask.ErrInvalidKeyID) {
+		t.Fatalf("expected ErrInvalidKeyID got %s instead", err)
+	}
+}
+
+func TestAEADTokenizer(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+
+	oldTokenizer, err := mask.NewAEADTokenizer(map[string][]byte{"old": oldKey}, "old", "enc_")
+	if err != nil {
+		t.Fatalf("expected to create tokenizer got %s instead", err)
+	}
+
+	oldToken := oldTokenizer.Tokenize("mark@domain.com")
+
+	tokenizer, err := mask.NewAEADTokenizer(map[string][]byte{"old": oldKey, "new": newKey}, "new", "enc_")
+	if err != nil {
+		t.Fatalf("expected to create tokenizer got %s instead", err)
+	}
+
+	token := tokenizer.Tokenize("mark@domain.com")
+
+	if !strings.HasPrefix(token, "enc_new.") {
+		t.Fatalf("expected token to embed active key id got %s", token)
+	}
+
+	if tokenizer.Tokenize("mark@domain.com") != token {
+		t.Fatalf("expected token to be stable for the same value")
+	}
+
+	for _, candidate := range []string{token, oldToken} {
+		value, err := tokenizer.Detokenize(candidate)
+		if err != nil {
+			t.Fatalf("expected to detokenize %s got %s instead", candidate, err)
+		}
+
+		if value != "mark@domain.com" {
+			t.Fatalf("expected to reveal original value got %s", value)
+		}
+	}
+
+	// token moved to another key id fails authentication
+	_, err = tokenizer.Detokenize(strings.Replace(token, "enc_new.", "enc_old.", 1))
+	if !errors.Is(err, mask.ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken got %s instead", err)
+	}
+
+	_, err = tokenizer.Detokenize("enc_unknown.AAAA")
+	if !errors.Is(err, mask.ErrUnknownKeyID) {
+		t.Fatalf("expected ErrUnknownKeyID got %s instead", err)
+	}
+
+	_, err = mask.NewAEADTokenizer(map[string][]byte{"old": oldKey}, "new", "")
+	if !errors.Is(err, mask.ErrUnknownKeyID) {
+		t.Fatalf("expected ErrUnknownKeyID for missing active key got %s instead", err)
+	}
+}
+
+func TestTokenMask(t *testing.T) {
+	tokenizer, err := mask.NewHMACTokenizer("k1", []byte("secret"), "", 8)
+	if err != nil {
+		t.Fatalf("expected to create tokenizer got %s instead", err)
+	}
+
+	tokenMask := &mask.TokenMask{Tokenizer: tokenizer}
+
+	if tokenMask.Mask(float64(30), mask.FieldTypeFloat64) != tokenizer.Tokenize("30") {
+		t.Fatalf("expected number to be tokenized by its decimal form")
+	}
+
+	if tokenMask.Mask(true, mask.FieldTypeBool) != tokenizer.Tokenize("true") {
+		t.Fatalf("expected boolean to be tokenized")
+	}
+}
@@ -0,0 +1,230 @@
+package mask
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"regexp"
+	"strings"
+)
+
+var ErrUnknownValuePattern = errors.New("unknown value pattern")
+
+const ValuePatternEmail = "email"
+const ValuePatternPhone = "phone"
+const ValuePatternCreditCard = "credit_card"
+const ValuePatternIBAN = "iban"
+const ValuePatternIP = "ip"
+const ValuePatternNationalID = "national_id"
+const ValuePatternJWT = "jwt"
+
+// ValueClassifier classifies field by its value instead of its name, so it also catches PII under generic keys and inside arrays
+type ValueClassifier interface {
+	ClassifyValue(value string, fieldType FieldType) (isClassified bool)
+}
+
+// PIIValueClassifier classifies string values and number literals matching any of its patterns
+type PIIValueClassifier struct {
+	patterns []PIIPattern
+}
+
+func NewPIIValueClassifier(patterns []PIIPattern) *PIIValueClassifier {
+	return &PIIValueClassifier{
+		patterns: patterns,
+	}
+}
+
+func (classifier *PIIValueClassifier) ClassifyValue(value string, fieldType FieldType) bool {
+	if fieldType != FieldTypeString && fieldType != FieldTypeFloat64 {
+		return false
+	}
+
+	for _, pattern := range classifier.patterns {
+		if pattern.IsPII([]byte(value)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func NewDefaultPIIValuePatterns() []PIIPattern {
+	patterns, _ := PIIValuePatterns([]string{
+		ValuePatternEmail,
+		ValuePatternPhone,
+		ValuePatternCreditCard,
+		ValuePatternIBAN,
+		ValuePatternIP,
+		ValuePatternNationalID,
+		ValuePatternJWT,
+	})
+
+	return patterns
+}
+
+// PIIValuePatterns returns value patterns by their names
+func PIIValuePatterns(names []string) ([]PIIPattern, error) {
+	patterns := make([]PIIPattern, 0, len(names))
+
+	for _, name := range names {
+		switch name {
+		case ValuePatternEmail:
+			patterns = append(patterns, &PIIClassifierPattern{Regexp: emailRegexp})
+		case ValuePatternPhone:
+			patterns = append(patterns, &PhonePattern{})
+		case ValuePatternCreditCard:
+			patterns = append(patterns, &CreditCardPattern{})
+		case ValuePatternIBAN:
+			patterns = append(patterns, &IBANPattern{})
+		case ValuePatternIP:
+			patterns = append(patterns, &IPPattern{})
+		case ValuePatternNationalID:
+			patterns = append(patterns, &PIIClassifierPattern{Regexp: nationalIDRegexp})
+		case ValuePatternJWT:
+			patterns = append(patterns, &JWTPattern{})
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnknownValuePattern, name)
+		}
+	}
+
+	return patterns, nil
+}
+
+var emailRegexp = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[a-zA-Z]{2,}$`)
+
+// national id formats of US social security number and UK national insurance number
+var nationalIDRegexp = regexp.MustCompile(`^(?:(?:00[1-9]|0[1-9]\d|[1-578]\d{2}|6[0-57-9]\d|66[0-57-9])-(?:0[1-9]|[1-9]\d)-(?:000[1-9]|00[1-9]\d|0[1-9]\d{2}|[1-9]\d{3})|[A-CEGHJ-PR-TW-Z]{2}\d{6}[A-D])$`)
+
+var internationalPhoneRegexp = regexp.MustCompile(`^\+\d[\d\s().-]+$`)
+var nationalPhoneRegexp = regexp.MustCompile(`^\(?\d{3}\)?[\s.-]?\d{3}[\s.-]\d{4}$`)
+
+// PhonePattern matches international numbers starting with "+" and 7 to 15 digits or grouped 10 digit national numbers,
+// plain digits aren't matched so ids and dates aren't mistaken for phones
+type PhonePattern struct{}
+
+func (pattern *PhonePattern) IsPII(input []byte) bool {
+	if nationalPhoneRegexp.Match(input) {
+		return true
+	}
+
+	if !internationalPhoneRegexp.Match(input) {
+		return false
+	}
+
+	digits := countDigits(input)
+
+	return digits >= 7 && digits <= 15
+}
+
+// CreditCardPattern matches 13 to 19 digits, optionally grouped by spaces or dashes, that pass Luhn check
+type CreditCardPattern struct{}
+
+func (pattern *CreditCardPattern) IsPII(input []byte) bool {
+	digits := make([]byte, 0, len(input))
+
+	for _, char := range input {
+		switch {
+		case char >= '0' && char <= '9':
+			digits = append(digits, char-'0')
+		case char == ' ' || char == '-':
+		default:
+			return false
+		}
+	}
+
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := range digits {
+		digit := int(digits[len(digits)-1-i])
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+
+		sum += digit
+	}
+
+	return sum%10 == 0
+}
+
+var ibanRegexp = regexp.MustCompile(`^[A-Z]{2}\d{2}[A-Z0-9]{11,30}$`)
+
+// IBANPattern matches IBANs, optionally grouped by spaces, with valid mod 97 checksum
+type IBANPattern struct{}
+
+func (pattern *IBANPattern) IsPII(input []byte) bool {
+	iban := strings.ToUpper(strings.ReplaceAll(string(input), " ", ""))
+	if !ibanRegexp.MatchString(iban) {
+		return false
+	}
+
+	// checksum is computed over iban with country code and check digits moved to the end and letters replaced by numbers
+	var numeric strings.Builder
+	for _, char := range iban[4:] + iban[:4] {
+		if char >= 'A' && char <= 'Z' {
+			numeric.WriteString(fmt.Sprint(char - 'A' + 10))
+		} else {
+			numeric.WriteRune(char)
+		}
+	}
+
+	number, ok := new(big.Int).SetString(numeric.String(), 10)
+	if !ok {
+		return false
+	}
+
+	return new(big.Int).Mod(number, big.NewInt(97)).Int64() == 1
+}
+
+// IPPattern matches IPv4 and IPv6 addresses
+type IPPattern struct{}
+
+func (pattern *IPPattern) IsPII(input []byte) bool {
+	return net.ParseIP(string(input)) != nil
+}
+
+// JWTPattern matches tokens of three base64url segments whose header is a json object with "alg"
+type JWTPattern struct{}
+
+func (pattern *JWTPattern) IsPII(input []byte) bool {
+	segments := strings.Split(string(input), ".")
+	if len(segments) != 3 || segments[1] == "" {
+		return false
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(segments[0])
+	if err != nil {
+		return false
+	}
+
+	var fields map[string]interface{}
+
+	err = json.Unmarshal(header, &fields)
+	if err != nil {
+		return false
+	}
+
+	_, ok := fields["alg"]
+
+	return ok
+}
+
+func countDigits(input []byte) int {
+	digits := 0
+
+	for _, char := range input {
+		if char >= '0' && char <= '9' {
+			digits++
+		}
+	}
+
+	return digits
+}
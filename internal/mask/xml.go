@@ -0,0 +1,262 @@
+package mask
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+var ErrDecodeXML = errors.New("failed to decode xml")
+var ErrWriteXML = errors.New("failed to write xml")
+var ErrUnknownCharset = errors.New("unknown xml encoding")
+
+// XMLInspector walks xml token by token masking text of elements and values of attributes.
+// Elements are classified by their local name and attributes by their name, paths of attributes end with "@name" segment.
+// Tokens are copied without resolving namespaces so prefixes and namespace declarations are kept as they were.
+// Document declaring other encoding than utf-8 is decoded for inspection and written back in its declared encoding
+type XMLInspector struct {
+	rules []Rule
+}
+
+func NewXMLInspector(mask Mask, classifier Classifier) StreamInspector {
+	return NewXMLRulesInspector([]Rule{
+		{
+			Classifier: classifier,
+			Mask:       mask,
+		},
+	})
+}
+
+func NewXMLRulesInspector(rules []Rule) StreamInspector {
+	return &XMLInspector{
+		rules: rules,
+	}
+}
+
+func (inspector *XMLInspector) Inspect(input []byte) ([]byte, error) {
+	buff := bytes.NewBuffer(nil)
+
+	err := inspector.InspectStream(bytes.NewReader(input), buff)
+	if err != nil {
+		return nil, err
+	}
+
+	return buff.Bytes(), nil
+}
+
+func (inspector *XMLInspector) InspectStream(input io.Reader, output io.Writer) error {
+	var declaredEncoding encoding.Encoding
+
+	decoder := xml.NewDecoder(input)
+	// decoder asks for charset reader while reading declaration of non utf-8 document
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		declaredEncoding, _ = charset.Lookup(label)
+		if declaredEncoding == nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCharset, label)
+		}
+
+		return declaredEncoding.NewDecoder().Reader(input), nil
+	}
+
+	writer := &xmlWriter{writer: bufio.NewWriter(output)}
+
+	names := []xml.Name{}
+	path := Path{}
+	// text of an element can be split into several tokens by CDATA sections, so it is masked once it is complete
+	text := bytes.NewBuffer(nil)
+
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrDecodeXML, err)
+		}
+
+		charData, isCharData := token.(xml.CharData)
+		if isCharData {
+			text.Write(charData)
+			continue
+		}
+
+		err = inspector.writeText(writer, path, text.String())
+		if err != nil {
+			return err
+		}
+
+		text.Reset()
+
+		switch value := token.(type) {
+		case xml.StartElement:
+			names = append(names, value.Name)
+			path = append(path, PathSegment{Key: value.Name.Local})
+
+			err = inspector.writeStart(writer, path, value)
+		case xml.EndElement:
+			if len(names) == 0 || names[len(names)-1] != value.Name {
+				return fmt.Errorf("%w: unexpected end element %s", ErrDecodeXML, xmlName(value.Name))
+			}
+
+			names = names[:len(names)-1]
+			path = path[:len(path)-1]
+
+			err = writer.WriteRaw("</" + xmlName(value.Name) + ">")
+		case xml.Comment:
+			err = writer.WriteRaw("<!--" + string(value) + "-->")
+		case xml.ProcInst:
+			err = writer.WriteRaw("<?" + value.Target + " " + string(value.Inst) + "?>")
+
+			if err == nil && value.Target == "xml" && declaredEncoding != nil {
+				err = writer.EncodeWith(declaredEncoding, output)
+			}
+		case xml.Directive:
+			err = writer.WriteRaw("<!" + string(value) + ">")
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(names) > 0 {
+		return fmt.Errorf("%w: unclosed element %s", ErrDecodeXML, xmlName(names[len(names)-1]))
+	}
+
+	err := inspector.writeText(writer, path, text.String())
+	if err != nil {
+		return err
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteXML, err)
+	}
+
+	return nil
+}
+
+func (inspector *XMLInspector) writeStart(writer *xmlWriter, path Path, element xml.StartElement) error {
+	err := writer.WriteRaw("<" + xmlName(element.Name))
+	if err != nil {
+		return err
+	}
+
+	for _, attr := range element.Attr {
+		value := attr.Value
+
+		if !isNamespaceDeclaration(attr.Name) {
+			attrPath := append(path, PathSegment{Key: attr.Name.Local, Attribute: true})
+
+			rule := firstMatchingRule(inspector.rules, attrPath, value, FieldTypeString)
+			if rule != nil {
+				value = maskedText(rule, value)
+			}
+		}
+
+		err = writer.WriteRaw(" " + xmlName(attr.Name) + `="`)
+		if err != nil {
+			return err
+		}
+
+		err = writer.WriteRaw(xmlAttrEscaper.Replace(value))
+		if err != nil {
+			return err
+		}
+
+		err = writer.WriteRaw(`"`)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.WriteRaw(">")
+}
+
+// writeText masks text of element at path, surrounding whitespace is kept and whitespace only text is never masked
+func (inspector *XMLInspector) writeText(writer *xmlWriter, path Path, text string) error {
+	value := strings.TrimFunc(text, unicode.IsSpace)
+
+	if value != "" && len(path) > 0 {
+		rule := firstMatchingRule(inspector.rules, path, value, FieldTypeString)
+		if rule != nil {
+			start := strings.Index(text, value)
+			text = text[:start] + maskedText(rule, value) + text[start+len(value):]
+		}
+	}
+
+	return writer.WriteRaw(xmlTextEscaper.Replace(text))
+}
+
+// masks can return values of other types, like null of whole masks, they are written as text
+func maskedText(rule *Rule, value string) string {
+	masked := rule.Mask.Mask(value, FieldTypeString)
+	if masked == nil {
+		return ""
+	}
+
+	return fmt.Sprint(masked)
+}
+
+func isNamespaceDeclaration(name xml.Name) bool {
+	return name.Space == "xmlns" || (name.Space == "" && name.Local == "xmlns")
+}
+
+// xmlName formats raw name with its prefix
+func xmlName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}
+
+// xml.EscapeText escapes new lines too, which would change formatting of the document
+var xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+var xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+
+type xmlWriter struct {
+	writer  *bufio.Writer
+	encoder io.WriteCloser
+}
+
+func (writer *xmlWriter) WriteRaw(raw string) error {
+	_, err := writer.writer.WriteString(raw)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteXML, err)
+	}
+
+	return nil
+}
+
+// EncodeWith writes rest of the document to output in declared encoding, declaration written before it is ascii
+func (writer *xmlWriter) EncodeWith(declaredEncoding encoding.Encoding, output io.Writer) error {
+	err := writer.writer.Flush()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteXML, err)
+	}
+
+	writer.encoder = transform.NewWriter(output, declaredEncoding.NewEncoder())
+	writer.writer = bufio.NewWriter(writer.encoder)
+
+	return nil
+}
+
+func (writer *xmlWriter) Flush() error {
+	err := writer.writer.Flush()
+	if err != nil || writer.encoder == nil {
+		return err
+	}
+
+	return writer.encoder.Close()
+}
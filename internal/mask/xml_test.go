@@ -0,0 +1,96 @@
+package mask
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestXMLInspector(t *testing.T) {
+	paths, err := ParsePathPatterns([]string{"$..user.@ssn"})
+	if err != nil {
+		t.Fatalf("failed to parse paths %s", err)
+	}
+
+	xmlInspector := NewXMLRulesInspector([]Rule{
+		{
+			Classifier: NewPIIClassifier(NewDefaultPIIPatterns()),
+			Values:     NewPIIValueClassifier(NewDefaultPIIValuePatterns()),
+			Paths:      paths,
+			Mask:       NewJSONMask(),
+		},
+		{
+			Classifier: NewPIIClassifier([]PIIPattern{&PIIClassifierPattern{Regexp: regexp.MustCompile(`^secret$`)}}),
+			Mask:       &MarkerMask{Marker: "[REDACTED]"},
+		},
+	})
+
+	testCases := []struct {
+		testName string
+		input    string
+		expected string
+	}{
+		{
+			testName: "soap_envelope",
+			input: `<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope" xmlns:u="urn:users">
+  <soap:Body>
+    <u:user id="7" ssn="123-45-6789" u:email="a@b.com">
+      <u:first_name>Mark &amp; co</u:first_name>
+      <u:note>call <!-- later --> +385 91 123 4567</u:note>
+      <u:secret><![CDATA[<hidden>]]></u:secret>
+      <u:empty/>
+    </u:user>
+  </soap:Body>
+</soap:Envelope>`,
+			expected: `<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope" xmlns:u="urn:users">
+  <soap:Body>
+    <u:user id="7" ssn="x" u:email="x">
+      <u:first_name>x</u:first_name>
+      <u:note>call <!-- later --> x</u:note>
+      <u:secret>[REDACTED]</u:secret>
+      <u:empty></u:empty>
+    </u:user>
+  </soap:Body>
+</soap:Envelope>`,
+		},
+		{
+			testName: "value_classification",
+			input:    `<contacts><contact>  mark@domain.com  </contact><count>2</count></contacts>`,
+			expected: `<contacts><contact>  x  </contact><count>2</count></contacts>`,
+		},
+		{
+			testName: "latin1_encoding",
+			input:    "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<user><first_name>Ren\xe9</first_name><city>Z\xfcrich</city></user>",
+			expected: "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<user><first_name>x</first_name><city>Z\xfcrich</city></user>",
+		},
+	}
+
+	for _, test := range testCases {
+		output, err := xmlInspector.Inspect([]byte(test.input))
+		if err != nil {
+			t.Fatalf("for test %s expected to inspect xml got %s instead", test.testName, err)
+		}
+
+		if string(output) != test.expected {
+			t.Fatalf("for test %s expected %s got %s", test.testName, test.expected, output)
+		}
+	}
+}
+
+func TestXMLInspectorError(t *testing.T) {
+	xmlInspector := NewXMLInspector(NewJSONMask(), &ClassifierMock{})
+
+	for _, input := range []string{"<a><b></a>", "<a>", "<a></b>", "<a x=1></a>", `<?xml version="1.0" encoding="x-unknown"?><a></a>`} {
+		output, err := xmlInspector.Inspect([]byte(input))
+
+		if output != nil {
+			t.Fatalf("expected nil output but got one instead")
+		}
+
+		if !errors.Is(err, ErrDecodeXML) {
+			t.Fatalf("expected to get wrapped error for input %s got %s", input, err)
+		}
+	}
+}
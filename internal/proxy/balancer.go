@@ -0,0 +1,237 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrNoTarget = errors.New("no target available")
+var ErrUnknownBalancer = errors.New("unknown balancer strategy")
+var ErrMissingHashHeader = errors.New("consistent hash balancer requires hash header")
+
+const BalancerRoundRobin = "round_robin"
+const BalancerWeightedRoundRobin = "weighted_round_robin"
+const BalancerLeastOutstanding = "least_outstanding"
+const BalancerConsistentHash = "consistent_hash"
+
+// number of points each target gets on consistent hash ring per unit of weight
+const hashRingReplicas = 100
+
+// Target is a single host:port of an upstream pool
+type Target struct {
+	Host   string
+	Weight int
+
+	outstanding atomic.Int64
+	health      targetHealth
+}
+
+func NewTarget(host string, weight int) *Target {
+	if weight <= 0 {
+		weight = 1
+	}
+
+	return &Target{
+		Host:   host,
+		Weight: weight,
+	}
+}
+
+// Outstanding is number of requests forwarded to target whose response body wasn't closed yet
+func (target *Target) Outstanding() int64 {
+	return target.outstanding.Load()
+}
+
+// Balancer picks one of healthy targets for request
+type Balancer interface {
+	Pick(req *http.Request) (*Target, error)
+}
+
+func NewBalancer(strategy string, targets []*Target, hashHeader string) (Balancer, error) {
+	switch strategy {
+	case "", BalancerRoundRobin:
+		return NewRoundRobinBalancer(targets), nil
+	case BalancerWeightedRoundRobin:
+		return NewWeightedRoundRobinBalancer(targets), nil
+	case BalancerLeastOutstanding:
+		return NewLeastOutstandingBalancer(targets), nil
+	case BalancerConsistentHash:
+		if hashHeader == "" {
+			return nil, ErrMissingHashHeader
+		}
+
+		return NewConsistentHashBalancer(targets, hashHeader), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownBalancer, strategy)
+}
+
+type RoundRobinBalancer struct {
+	targets []*Target
+	next    atomic.Uint64
+}
+
+func NewRoundRobinBalancer(targets []*Target) *RoundRobinBalancer {
+	return &RoundRobinBalancer{
+		targets: targets,
+	}
+}
+
+func (balancer *RoundRobinBalancer) Pick(req *http.Request) (*Target, error) {
+	for range balancer.targets {
+		next := balancer.next.Add(1) - 1
+
+		target := balancer.targets[next%uint64(len(balancer.targets))]
+		if target.Healthy() {
+			return target, nil
+		}
+	}
+
+	return nil, ErrNoTarget
+}
+
+// WeightedRoundRobinBalancer uses smooth weighted round robin so heavier targets aren't picked in bursts
+type WeightedRoundRobinBalancer struct {
+	targets []*Target
+	current []int
+	mu      sync.Mutex
+}
+
+func NewWeightedRoundRobinBalancer(targets []*Target) *WeightedRoundRobinBalancer {
+	return &WeightedRoundRobinBalancer{
+		targets: targets,
+		current: make([]int, len(targets)),
+	}
+}
+
+func (balancer *WeightedRoundRobinBalancer) Pick(req *http.Request) (*Target, error) {
+	balancer.mu.Lock()
+	defer balancer.mu.Unlock()
+
+	best := -1
+	total := 0
+
+	for i, target := range balancer.targets {
+		if !target.Healthy() {
+			continue
+		}
+
+		balancer.current[i] += target.Weight
+		total += target.Weight
+
+		if best == -1 || balancer.current[i] > balancer.current[best] {
+			best = i
+		}
+	}
+
+	if best == -1 {
+		return nil, ErrNoTarget
+	}
+
+	balancer.current[best] -= total
+
+	return balancer.targets[best], nil
+}
+
+type LeastOutstandingBalancer struct {
+	targets []*Target
+	next    atomic.Uint64
+}
+
+func NewLeastOutstandingBalancer(targets []*Target) *LeastOutstandingBalancer {
+	return &LeastOutstandingBalancer{
+		targets: targets,
+	}
+}
+
+func (balancer *LeastOutstandingBalancer) Pick(req *http.Request) (*Target, error) {
+	// start from a rotating offset so ties don't always go to the first target
+	start := balancer.next.Add(1) - 1
+
+	var best *Target
+	for i := range balancer.targets {
+		target := balancer.targets[(start+uint64(i))%uint64(len(balancer.targets))]
+		if !target.Healthy() {
+			continue
+		}
+
+		if best == nil || target.Outstanding() < best.Outstanding() {
+			best = target
+		}
+	}
+
+	if best == nil {
+		return nil, ErrNoTarget
+	}
+
+	return best, nil
+}
+
+type hashRingPoint struct {
+	hash   uint32
+	target *Target
+}
+
+// ConsistentHashBalancer sends requests with same header value to same target,
+// requests without header are hashed by client address
+type ConsistentHashBalancer struct {
+	header string
+	ring   []hashRingPoint
+}
+
+func NewConsistentHashBalancer(targets []*Target, header string) *ConsistentHashBalancer {
+	ring := []hashRingPoint{}
+
+	for _, target := range targets {
+		for i := 0; i < hashRingReplicas*target.Weight; i++ {
+			ring = append(ring, hashRingPoint{
+				hash:   crc32.ChecksumIEEE([]byte(target.Host + "#" + strconv.Itoa(i))),
+				target: target,
+			})
+		}
+	}
+
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+
+	return &ConsistentHashBalancer{
+		header: header,
+		ring:   ring,
+	}
+}
+
+func (balancer *ConsistentHashBalancer) Pick(req *http.Request) (*Target, error) {
+	key := req.Header.Get(balancer.header)
+	if key == "" {
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			host = req.RemoteAddr
+		}
+
+		key = host
+	}
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+
+	index := sort.Search(len(balancer.ring), func(i int) bool {
+		return balancer.ring[i].hash >= hash
+	})
+
+	// walk the ring clockwise so keys of unhealthy target move to its neighbours
+	for i := range balancer.ring {
+		target := balancer.ring[(index+i)%len(balancer.ring)].target
+		if target.Healthy() {
+			return target, nil
+		}
+	}
+
+	return nil, ErrNoTarget
+}
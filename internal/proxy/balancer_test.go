@@ -0,0 +1,137 @@
+package proxy_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/vjerci/reverse-proxy/internal/proxy"
+)
+
+func pickHosts(t *testing.T, balancer proxy.Balancer, count int) []string {
+	hosts := []string{}
+
+	for i := 0; i < count; i++ {
+		target, err := balancer.Pick(httptest.NewRequest(http.MethodGet, "http://localhost", http.NoBody))
+		if err != nil {
+			t.Fatalf("failed to pick target %s", err)
+		}
+
+		hosts = append(hosts, target.Host)
+	}
+
+	return hosts
+}
+
+func TestRoundRobinBalancer(t *testing.T) {
+	balancer := proxy.NewRoundRobinBalancer([]*proxy.Target{
+		proxy.NewTarget("a", 1),
+		proxy.NewTarget("b", 1),
+		proxy.NewTarget("c", 1),
+	})
+
+	hosts := pickHosts(t, balancer, 6)
+	expected := []string{"a", "b", "c", "a", "b", "c"}
+
+	for i := range expected {
+		if hosts[i] != expected[i] {
+			t.Fatalf("expected hosts %v got %v instead", expected, hosts)
+		}
+	}
+}
+
+func TestWeightedRoundRobinBalancer(t *testing.T) {
+	balancer := proxy.NewWeightedRoundRobinBalancer([]*proxy.Target{
+		proxy.NewTarget("a", 5),
+		proxy.NewTarget("b", 1),
+		proxy.NewTarget("c", 1),
+	})
+
+	hosts := pickHosts(t, balancer, 7)
+	expected := []string{"a", "a", "b", "a", "c", "a", "a"}
+
+	for i := range expected {
+		if hosts[i] != expected[i] {
+			t.Fatalf("expected hosts %v got %v instead", expected, hosts)
+		}
+	}
+}
+
+func TestLeastOutstandingBalancer(t *testing.T) {
+	busy := proxy.NewTarget("busy", 1)
+	idle := proxy.NewTarget("idle", 1)
+
+	clientMock := &HTTPClientMock{
+		Resp: &http.Response{},
+	}
+	proxyInstance := proxy.NewProxy(clientMock)
+
+	// keep one request outstanding on busy target
+	_, err := proxyInstance.Forward(httptest.NewRequest(http.MethodGet, "http://localhost", http.NoBody), &proxy.Upstream{
+		Name:     "busy",
+		Scheme:   "http",
+		Balancer: proxy.NewRoundRobinBalancer([]*proxy.Target{busy}),
+	})
+	if err != nil {
+		t.Fatalf("failed to forward request %s", err)
+	}
+
+	balancer := proxy.NewLeastOutstandingBalancer([]*proxy.Target{busy, idle})
+
+	for _, host := range pickHosts(t, balancer, 4) {
+		if host != "idle" {
+			t.Fatalf("expected to always pick idle target got %s instead", host)
+		}
+	}
+}
+
+func TestConsistentHashBalancer(t *testing.T) {
+	balancer := proxy.NewConsistentHashBalancer([]*proxy.Target{
+		proxy.NewTarget("a", 1),
+		proxy.NewTarget("b", 1),
+		proxy.NewTarget("c", 1),
+	}, "X-User-ID")
+
+	picked := map[string]bool{}
+
+	for i := 0; i < 50; i++ {
+		req := httptest.NewRequest(http.MethodGet, "http://localhost", http.NoBody)
+		req.Header.Set("X-User-ID", "user-"+strconv.Itoa(i))
+
+		first, err := balancer.Pick(req)
+		if err != nil {
+			t.Fatalf("failed to pick target %s", err)
+		}
+
+		second, err := balancer.Pick(req)
+		if err != nil {
+			t.Fatalf("failed to pick target %s", err)
+		}
+
+		if first != second {
+			t.Fatalf("expected same header value to pick same target got %s and %s", first.Host, second.Host)
+		}
+
+		picked[first.Host] = true
+	}
+
+	if len(picked) != 3 {
+		t.Fatalf("expected keys to spread across all targets got %v instead", picked)
+	}
+}
+
+func TestNewBalancerErrors(t *testing.T) {
+	targets := []*proxy.Target{proxy.NewTarget("a", 1)}
+
+	_, err := proxy.NewBalancer("random", targets, "")
+	if !errors.Is(err, proxy.ErrUnknownBalancer) {
+		t.Fatalf("expected ErrUnknownBalancer got %s instead", err)
+	}
+
+	_, err = proxy.NewBalancer(proxy.BalancerConsistentHash, targets, "")
+	if !errors.Is(err, proxy.ErrMissingHashHeader) {
+		t.Fatalf("expected ErrMissingHashHeader got %s instead", err)
+	}
+}
@@ -0,0 +1,274 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+const DefaultCircuitFailureRatio = 0.5
+const DefaultCircuitWindow = 10 * time.Second
+const DefaultCircuitOpenDuration = 30 * time.Second
+
+// number of buckets rolling window is split into
+const circuitBuckets = 10
+
+type CircuitState string
+
+const CircuitClosed = CircuitState("closed")
+const CircuitOpen = CircuitState("open")
+const CircuitHalfOpen = CircuitState("half_open")
+
+// CircuitBreakerPolicy opens circuit when ratio of failed requests within rolling Window reaches FailureRatio,
+// after OpenDuration HalfOpenRequests probe requests are let through to decide whether to close it again.
+// Forwarding errors and 5xx responses count as failures.
+type CircuitBreakerPolicy struct {
+	FailureRatio     float64
+	MinRequests      int
+	Window           time.Duration
+	OpenDuration     time.Duration
+	HalfOpenRequests int
+}
+
+type circuitBucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+type circuitBreaker struct {
+	mu     sync.Mutex
+	policy *CircuitBreakerPolicy
+
+	state    CircuitState
+	openedAt time.Time
+	buckets  [circuitBuckets]circuitBucket
+
+	halfOpenInFlight  int
+	halfOpenSuccesses int
+
+	// generation changes whenever circuit opens or closes, so outcomes of requests admitted before are ignored
+	generation uint64
+}
+
+// circuitAdmission tells which state of circuit request was let through in
+type circuitAdmission struct {
+	probe      bool
+	generation uint64
+}
+
+func newCircuitBreaker(policy *CircuitBreakerPolicy) *circuitBreaker {
+	return &circuitBreaker{
+		policy: policy,
+		state:  CircuitClosed,
+	}
+}
+
+func (breaker *circuitBreaker) window() time.Duration {
+	if breaker.policy.Window <= 0 {
+		return DefaultCircuitWindow
+	}
+
+	return breaker.policy.Window
+}
+
+func (breaker *circuitBreaker) failureRatio() float64 {
+	if breaker.policy.FailureRatio <= 0 {
+		return DefaultCircuitFailureRatio
+	}
+
+	return breaker.policy.FailureRatio
+}
+
+func (breaker *circuitBreaker) halfOpenRequests() int {
+	if breaker.policy.HalfOpenRequests <= 0 {
+		return 1
+	}
+
+	return breaker.policy.HalfOpenRequests
+}
+
+func (breaker *circuitBreaker) allow(now time.Time) (circuitAdmission, bool) {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	if breaker.state == CircuitOpen {
+		openDuration := breaker.policy.OpenDuration
+		if openDuration <= 0 {
+			openDuration = DefaultCircuitOpenDuration
+		}
+
+		if now.Sub(breaker.openedAt) < openDuration {
+			return circuitAdmission{}, false
+		}
+
+		breaker.state = CircuitHalfOpen
+		breaker.halfOpenInFlight = 0
+		breaker.halfOpenSuccesses = 0
+	}
+
+	if breaker.state == CircuitHalfOpen {
+		if breaker.halfOpenInFlight >= breaker.halfOpenRequests() {
+			return circuitAdmission{}, false
+		}
+
+		breaker.halfOpenInFlight++
+
+		return circuitAdmission{probe: true, generation: breaker.generation}, true
+	}
+
+	return circuitAdmission{generation: breaker.generation}, true
+}
+
+// record counts outcome of admitted request, only probes decide half open circuit and
+// requests admitted before circuit last opened or closed aren't counted at all
+func (breaker *circuitBreaker) record(now time.Time, admission circuitAdmission, success bool) {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	if admission.generation != breaker.generation {
+		return
+	}
+
+	switch breaker.state {
+	case CircuitHalfOpen:
+		if !admission.probe {
+			return
+		}
+
+		breaker.halfOpenInFlight--
+
+		if !success {
+			breaker.open(now)
+			return
+		}
+
+		breaker.halfOpenSuccesses++
+		if breaker.halfOpenSuccesses >= breaker.halfOpenRequests() {
+			breaker.state = CircuitClosed
+			breaker.buckets = [circuitBuckets]circuitBucket{}
+			breaker.generation++
+		}
+	case CircuitClosed:
+		bucket := breaker.bucket(now)
+		if success {
+			bucket.successes++
+		} else {
+			bucket.failures++
+		}
+
+		successes, failures := breaker.totals(now)
+		total := successes + failures
+
+		if total >= breaker.policy.MinRequests && total > 0 && float64(failures)/float64(total) >= breaker.failureRatio() {
+			breaker.open(now)
+		}
+	}
+}
+
+func (breaker *circuitBreaker) open(now time.Time) {
+	breaker.state = CircuitOpen
+	breaker.openedAt = now
+	breaker.buckets = [circuitBuckets]circuitBucket{}
+	breaker.generation++
+}
+
+func (breaker *circuitBreaker) bucket(now time.Time) *circuitBucket {
+	// windows shorter than circuitBuckets nanoseconds would make buckets empty
+	bucketSize := breaker.window() / circuitBuckets
+	if bucketSize <= 0 {
+		bucketSize = 1
+	}
+
+	start := now.Truncate(bucketSize)
+
+	bucket := &breaker.buckets[(start.UnixNano()/int64(bucketSize))%circuitBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = circuitBucket{
+			start: start,
+		}
+	}
+
+	return bucket
+}
+
+func (breaker *circuitBreaker) totals(now time.Time) (successes int, failures int) {
+	for _, bucket := range breaker.buckets {
+		if now.Sub(bucket.start) < breaker.window() {
+			successes += bucket.successes
+			failures += bucket.failures
+		}
+	}
+
+	return successes, failures
+}
+
+func (breaker *circuitBreaker) currentState() CircuitState {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	return breaker.state
+}
+
+// CircuitBreakerProxy keeps a circuit breaker per upstream and fails fast with ErrCircuitOpen while it is open
+type CircuitBreakerProxy struct {
+	next     Proxy
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func NewCircuitBreakerProxy(next Proxy) *CircuitBreakerProxy {
+	return &CircuitBreakerProxy{
+		next:     next,
+		breakers: map[string]*circuitBreaker{},
+	}
+}
+
+func (proxy *CircuitBreakerProxy) breaker(upstream *Upstream) *circuitBreaker {
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+
+	breaker, ok := proxy.breakers[upstream.Name]
+	if !ok {
+		breaker = newCircuitBreaker(upstream.CircuitBreaker)
+		proxy.breakers[upstream.Name] = breaker
+	}
+
+	return breaker
+}
+
+func (proxy *CircuitBreakerProxy) Forward(req *http.Request, upstream *Upstream) (*http.Response, error) {
+	if upstream.CircuitBreaker == nil {
+		return proxy.next.Forward(req, upstream)
+	}
+
+	breaker := proxy.breaker(upstream)
+
+	admission, ok := breaker.allow(time.Now())
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, upstream.Name)
+	}
+
+	resp, err := proxy.next.Forward(req, upstream)
+
+	breaker.record(time.Now(), admission, err == nil && resp.StatusCode < http.StatusInternalServerError)
+
+	return resp, err
+}
+
+// State returns state of circuit breaker for upstream, upstreams without traffic are closed
+func (proxy *CircuitBreakerProxy) State(upstreamName string) CircuitState {
+	proxy.mu.Lock()
+	breaker, ok := proxy.breakers[upstreamName]
+	proxy.mu.Unlock()
+
+	if !ok {
+		return CircuitClosed
+	}
+
+	return breaker.currentState()
+}
@@ -0,0 +1,213 @@
+package proxy_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vjerci/reverse-proxy/internal/proxy"
+)
+
+func TestCircuitBreakerProxy(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	calls := 0
+
+	breakerProxy := proxy.NewCircuitBreakerProxy(&ProxyFuncMock{
+		method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+			calls++
+			return &http.Response{
+				StatusCode: status,
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		},
+	})
+
+	upstream := &proxy.Upstream{
+		Name: "api",
+		CircuitBreaker: &proxy.CircuitBreakerPolicy{
+			FailureRatio: 0.5,
+			MinRequests:  4,
+			Window:       time.Minute,
+			OpenDuration: 50 * time.Millisecond,
+		},
+	}
+
+	forward := func() error {
+		_, err := breakerProxy.Forward(httptest.NewRequest(http.MethodGet, "http://localhost", http.NoBody), upstream)
+		return err
+	}
+
+	for i := 0; i < 4; i++ {
+		err := forward()
+		if err != nil {
+			t.Fatalf("expected request %d to pass through closed circuit got %s instead", i, err)
+		}
+	}
+
+	if breakerProxy.State("api") != proxy.CircuitOpen {
+		t.Fatalf("expected circuit to open after failures got %s instead", breakerProxy.State("api"))
+	}
+
+	err := forward()
+	if !errors.Is(err, proxy.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen got %s instead", err)
+	}
+
+	if calls != 4 {
+		t.Fatalf("expected open circuit to fail fast without forwarding got %d calls", calls)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	// failed probe opens circuit again
+	err = forward()
+	if err != nil {
+		t.Fatalf("expected half open circuit to let probe through got %s instead", err)
+	}
+
+	if breakerProxy.State("api") != proxy.CircuitOpen {
+		t.Fatalf("expected failed probe to open circuit got %s instead", breakerProxy.State("api"))
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	status = http.StatusOK
+
+	err = forward()
+	if err != nil {
+		t.Fatalf("expected half open circuit to let probe through got %s instead", err)
+	}
+
+	if breakerProxy.State("api") != proxy.CircuitClosed {
+		t.Fatalf("expected successful probe to close circuit got %s instead", breakerProxy.State("api"))
+	}
+}
+
+func TestCircuitBreakerProxyMinRequests(t *testing.T) {
+	breakerProxy := proxy.NewCircuitBreakerProxy(&ProxyFuncMock{
+		method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+			return nil, errors.New("dummy error")
+		},
+	})
+
+	upstream := &proxy.Upstream{
+		Name: "api",
+		CircuitBreaker: &proxy.CircuitBreakerPolicy{
+			FailureRatio: 0.5,
+			MinRequests:  10,
+		},
+	}
+
+	for i := 0; i < 9; i++ {
+		breakerProxy.Forward(httptest.NewRequest(http.MethodGet, "http://localhost", http.NoBody), upstream)
+	}
+
+	if breakerProxy.State("api") != proxy.CircuitClosed {
+		t.Fatalf("expected circuit to stay closed below min requests got %s instead", breakerProxy.State("api"))
+	}
+}
+
+func TestCircuitBreakerProxyTinyWindow(t *testing.T) {
+	breakerProxy := proxy.NewCircuitBreakerProxy(&ProxyFuncMock{
+		method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+			return nil, errors.New("dummy error")
+		},
+	})
+
+	upstream := &proxy.Upstream{
+		Name: "api",
+		CircuitBreaker: &proxy.CircuitBreakerPolicy{
+			FailureRatio: 0.5,
+			Window:       5 * time.Nanosecond,
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := breakerProxy.Forward(httptest.NewRequest(http.MethodGet, "http://localhost", http.NoBody), upstream)
+		if err == nil {
+			t.Fatalf("expected forwarding error on attempt %d", i)
+		}
+	}
+}
+
+func TestCircuitBreakerProxySlowRequest(t *testing.T) {
+	started := make(chan string)
+	release := map[string]chan struct{}{
+		"/slow":  make(chan struct{}),
+		"/probe": make(chan struct{}),
+	}
+
+	breakerProxy := proxy.NewCircuitBreakerProxy(&ProxyFuncMock{
+		method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+			wait, ok := release[req.URL.Path]
+			if !ok {
+				return nil, errors.New("dummy error")
+			}
+
+			started <- req.URL.Path
+			<-wait
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		},
+	})
+
+	upstream := &proxy.Upstream{
+		Name: "api",
+		CircuitBreaker: &proxy.CircuitBreakerPolicy{
+			FailureRatio: 0.5,
+			MinRequests:  4,
+			Window:       time.Minute,
+			OpenDuration: 50 * time.Millisecond,
+		},
+	}
+
+	forward := func(path string) error {
+		_, err := breakerProxy.Forward(httptest.NewRequest(http.MethodGet, "http://localhost"+path, http.NoBody), upstream)
+		return err
+	}
+
+	done := make(chan error)
+
+	go func() { done <- forward("/slow") }()
+	<-started
+
+	for i := 0; i < 4; i++ {
+		forward("/")
+	}
+
+	if breakerProxy.State("api") != proxy.CircuitOpen {
+		t.Fatalf("expected circuit to open after failures got %s instead", breakerProxy.State("api"))
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	go func() { done <- forward("/probe") }()
+	<-started
+
+	// request admitted before circuit opened doesn't decide half open circuit
+	close(release["/slow"])
+	<-done
+
+	if breakerProxy.State("api") != proxy.CircuitHalfOpen {
+		t.Fatalf("expected slow request not to close circuit got %s instead", breakerProxy.State("api"))
+	}
+
+	err := forward("/")
+	if !errors.Is(err, proxy.ErrCircuitOpen) {
+		t.Fatalf("expected slow request not to free probe slot got %v instead", err)
+	}
+
+	close(release["/probe"])
+	<-done
+
+	if breakerProxy.State("api") != proxy.CircuitClosed {
+		t.Fatalf("expected successful probe to close circuit got %s instead", breakerProxy.State("api"))
+	}
+}
@@ -0,0 +1,214 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const DefaultHealthCheckInterval = 10 * time.Second
+const DefaultHealthCheckTimeout = 2 * time.Second
+
+// ActiveHealthCheck periodically probes each target of upstream
+type ActiveHealthCheck struct {
+	Path           string
+	Interval       time.Duration
+	Timeout        time.Duration
+	ExpectedStatus int
+}
+
+// PassiveHealthCheck ejects target after MaxFailures consecutive forwarding errors for Cooldown duration
+type PassiveHealthCheck struct {
+	MaxFailures int
+	Cooldown    time.Duration
+}
+
+type targetHealth struct {
+	mu                  sync.Mutex
+	failedActiveCheck   bool
+	consecutiveFailures int
+	ejectedUntil        time.Time
+}
+
+type TargetStatus struct {
+	Host                string    `json:"host"`
+	Healthy             bool      `json:"healthy"`
+	FailedActiveCheck   bool      `json:"failed_active_check"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	EjectedUntil        time.Time `json:"ejected_until"`
+	Outstanding         int64     `json:"outstanding"`
+}
+
+func (target *Target) Healthy() bool {
+	target.health.mu.Lock()
+	defer target.health.mu.Unlock()
+
+	return target.healthyLocked(time.Now())
+}
+
+func (target *Target) healthyLocked(now time.Time) bool {
+	return !target.health.failedActiveCheck && !now.Before(target.health.ejectedUntil)
+}
+
+func (target *Target) Status() TargetStatus {
+	target.health.mu.Lock()
+	defer target.health.mu.Unlock()
+
+	return TargetStatus{
+		Host:                target.Host,
+		Healthy:             target.healthyLocked(time.Now()),
+		FailedActiveCheck:   target.health.failedActiveCheck,
+		ConsecutiveFailures: target.health.consecutiveFailures,
+		EjectedUntil:        target.health.ejectedUntil,
+		Outstanding:         target.Outstanding(),
+	}
+}
+
+func (target *Target) ReportSuccess() {
+	target.health.mu.Lock()
+	defer target.health.mu.Unlock()
+
+	target.health.consecutiveFailures = 0
+}
+
+func (target *Target) ReportFailure(check *PassiveHealthCheck) {
+	target.health.mu.Lock()
+	defer target.health.mu.Unlock()
+
+	target.health.consecutiveFailures++
+
+	if check != nil && check.MaxFailures > 0 && target.health.consecutiveFailures >= check.MaxFailures {
+		target.health.ejectedUntil = time.Now().Add(check.Cooldown)
+		target.health.consecutiveFailures = 0
+	}
+}
+
+func (target *Target) setActiveCheckResult(passed bool) {
+	target.health.mu.Lock()
+	defer target.health.mu.Unlock()
+
+	target.health.failedActiveCheck = !passed
+}
+
+type HealthChecker struct {
+	client    HTTPClient
+	upstreams []*Upstream
+}
+
+func NewHealthChecker(client HTTPClient, upstreams []*Upstream) *HealthChecker {
+	return &HealthChecker{
+		client:    client,
+		upstreams: upstreams,
+	}
+}
+
+// Start probes upstreams with active health check configured until ctx is done
+func (checker *HealthChecker) Start(ctx context.Context) {
+	for _, upstream := range checker.upstreams {
+		if upstream.HealthCheck == nil {
+			continue
+		}
+
+		go checker.run(ctx, upstream)
+	}
+}
+
+func (checker *HealthChecker) run(ctx context.Context, upstream *Upstream) {
+	interval := upstream.HealthCheck.Interval
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		checker.CheckUpstream(ctx, upstream)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckUpstream probes every target of upstream once
+func (checker *HealthChecker) CheckUpstream(ctx context.Context, upstream *Upstream) {
+	var wg sync.WaitGroup
+
+	for _, target := range upstream.Targets {
+		wg.Add(1)
+
+		go func(target *Target) {
+			defer wg.Done()
+			target.setActiveCheckResult(checker.probe(ctx, upstream, target))
+		}(target)
+	}
+
+	wg.Wait()
+}
+
+func (checker *HealthChecker) probe(ctx context.Context, upstream *Upstream, target *Target) bool {
+	check := upstream.HealthCheck
+
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+
+	expectedStatus := check.ExpectedStatus
+	if expectedStatus == 0 {
+		expectedStatus = http.StatusOK
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	probeURL := url.URL{
+		Scheme: upstream.Scheme,
+		Host:   target.Host,
+		Path:   check.Path,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL.String(), http.NoBody)
+	if err != nil {
+		return false
+	}
+
+	resp, err := checker.client.Do(req)
+	if err != nil {
+		return false
+	}
+
+	if resp.Body != nil {
+		resp.Body.Close()
+	}
+
+	return resp.StatusCode == expectedStatus
+}
+
+func (checker *HealthChecker) Status() map[string][]TargetStatus {
+	status := make(map[string][]TargetStatus, len(checker.upstreams))
+
+	for _, upstream := range checker.upstreams {
+		targets := make([]TargetStatus, 0, len(upstream.Targets))
+		for _, target := range upstream.Targets {
+			targets = append(targets, target.Status())
+		}
+
+		status[upstream.Name] = targets
+	}
+
+	return status
+}
+
+// ServeHTTP responds with health status of all upstream targets as json
+func (checker *HealthChecker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(checker.Status())
+}
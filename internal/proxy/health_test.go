@@ -0,0 +1,118 @@
+package proxy_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vjerci/reverse-proxy/internal/proxy"
+)
+
+type HTTPClientFuncMock struct {
+	method func(req *http.Request) (*http.Response, error)
+}
+
+func (client *HTTPClientFuncMock) Do(req *http.Request) (*http.Response, error) {
+	return client.method(req)
+}
+
+func TestPassiveHealthCheck(t *testing.T) {
+	targets := []*proxy.Target{proxy.NewTarget("a", 1)}
+	upstream := &proxy.Upstream{
+		Name:     "api",
+		Scheme:   "http",
+		Targets:  targets,
+		Balancer: proxy.NewRoundRobinBalancer(targets),
+		PassiveHealthCheck: &proxy.PassiveHealthCheck{
+			MaxFailures: 2,
+			Cooldown:    50 * time.Millisecond,
+		},
+	}
+
+	proxyInstance := proxy.NewProxy(&HTTPClientMock{
+		Err: errors.New("dummy error"),
+	})
+
+	for i := 0; i < 2; i++ {
+		if !targets[0].Healthy() {
+			t.Fatalf("expected target to be healthy before %d failures", upstream.PassiveHealthCheck.MaxFailures)
+		}
+
+		_, err := proxyInstance.Forward(httptest.NewRequest(http.MethodGet, "http://localhost", http.NoBody), upstream)
+		if !errors.Is(err, proxy.ErrFailedToForward) {
+			t.Fatalf("expected ErrFailedToForward got %s instead", err)
+		}
+	}
+
+	if targets[0].Healthy() {
+		t.Fatal("expected target to be ejected after consecutive failures")
+	}
+
+	_, err := proxyInstance.Forward(httptest.NewRequest(http.MethodGet, "http://localhost", http.NoBody), upstream)
+	if !errors.Is(err, proxy.ErrNoTarget) {
+		t.Fatalf("expected ejected target to be skipped got %s instead", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if !targets[0].Healthy() {
+		t.Fatal("expected target to be re-admitted after cooldown")
+	}
+}
+
+func TestActiveHealthCheck(t *testing.T) {
+	targets := []*proxy.Target{proxy.NewTarget("a", 1), proxy.NewTarget("b", 1)}
+	upstream := &proxy.Upstream{
+		Name:     "api",
+		Scheme:   "http",
+		Targets:  targets,
+		Balancer: proxy.NewRoundRobinBalancer(targets),
+		HealthCheck: &proxy.ActiveHealthCheck{
+			Path:           "/health",
+			ExpectedStatus: http.StatusNoContent,
+		},
+	}
+
+	checker := proxy.NewHealthChecker(&HTTPClientFuncMock{
+		method: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path != "/health" {
+				t.Errorf("expected probe to /health got %s instead", req.URL.Path)
+			}
+
+			if req.URL.Host == "b" {
+				return &http.Response{StatusCode: http.StatusServiceUnavailable}, nil
+			}
+
+			return &http.Response{StatusCode: http.StatusNoContent}, nil
+		},
+	}, []*proxy.Upstream{upstream})
+
+	checker.CheckUpstream(context.Background(), upstream)
+
+	for _, host := range pickHosts(t, upstream.Balancer, 4) {
+		if host != "a" {
+			t.Fatalf("expected unhealthy target to be skipped got %s instead", host)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	checker.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/health", http.NoBody))
+
+	var status map[string][]proxy.TargetStatus
+	err := json.NewDecoder(recorder.Body).Decode(&status)
+	if err != nil {
+		t.Fatalf("failed to decode health status %s", err)
+	}
+
+	if len(status["api"]) != 2 {
+		t.Fatalf("expected status of 2 targets got %#v instead", status)
+	}
+
+	if !status["api"][0].Healthy || status["api"][1].Healthy || !status["api"][1].FailedActiveCheck {
+		t.Fatalf("expected only target b to fail active check got %#v instead", status)
+	}
+}
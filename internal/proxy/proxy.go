@@ -3,21 +3,35 @@ package proxy
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"sync"
 )
 
 var ErrFailedToForward = errors.New("failed to forward request")
 var ErrFailedToBuildURL = errors.New("failed to build url")
 
 type Proxy interface {
-	Forward(req *http.Request, host string, scheme string) (*http.Response, error)
+	Forward(req *http.Request, upstream *Upstream) (*http.Response, error)
 }
 
 type HTTPClient interface {
 	Do(*http.Request) (*http.Response, error)
 }
 
+// Upstream is a named pool of targets requests can be routed to
+type Upstream struct {
+	Name               string
+	Scheme             string
+	Targets            []*Target
+	Balancer           Balancer
+	HealthCheck        *ActiveHealthCheck
+	PassiveHealthCheck *PassiveHealthCheck
+	RetryPolicy        *RetryPolicy
+	CircuitBreaker     *CircuitBreakerPolicy
+}
+
 type ProxyInstance struct {
 	http HTTPClient
 }
@@ -28,9 +42,14 @@ func NewProxy(client HTTPClient) Proxy {
 	}
 }
 
-func (proxy *ProxyInstance) Forward(req *http.Request, host string, scheme string) (*http.Response, error) {
-	req.URL.Host = host
-	req.URL.Scheme = scheme
+func (proxy *ProxyInstance) Forward(req *http.Request, upstream *Upstream) (*http.Response, error) {
+	target, err := upstream.Balancer.Pick(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedToForward, err)
+	}
+
+	req.URL.Host = target.Host
+	req.URL.Scheme = upstream.Scheme
 	u, err := url.Parse(req.URL.String())
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrFailedToBuildURL, err)
@@ -39,10 +58,40 @@ func (proxy *ProxyInstance) Forward(req *http.Request, host string, scheme strin
 
 	req.RequestURI = ""
 
+	target.outstanding.Add(1)
+
 	resp, err := proxy.http.Do(req)
 	if err != nil {
+		target.outstanding.Add(-1)
+		target.ReportFailure(upstream.PassiveHealthCheck)
 		return nil, fmt.Errorf("%w: %w", ErrFailedToForward, err)
 	}
 
+	target.ReportSuccess()
+
+	resp.Body = &outstandingBody{
+		ReadCloser: resp.Body,
+		target:     target,
+	}
+
 	return resp, nil
 }
+
+// outstandingBody keeps request counted as outstanding on its target until response body is closed
+type outstandingBody struct {
+	io.ReadCloser
+	target *Target
+	once   sync.Once
+}
+
+func (body *outstandingBody) Close() error {
+	body.once.Do(func() {
+		body.target.outstanding.Add(-1)
+	})
+
+	if body.ReadCloser == nil {
+		return nil
+	}
+
+	return body.ReadCloser.Close()
+}
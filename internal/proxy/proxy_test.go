@@ -20,6 +20,17 @@ func (client *HTTPClientMock) Do(req *http.Request) (*http.Response, error) {
 	return client.Resp, client.Err
 }
 
+func newUpstream(host string, scheme string) *proxy.Upstream {
+	targets := []*proxy.Target{proxy.NewTarget(host, 1)}
+
+	return &proxy.Upstream{
+		Name:     "api",
+		Scheme:   scheme,
+		Targets:  targets,
+		Balancer: proxy.NewRoundRobinBalancer(targets),
+	}
+}
+
 func TestHttpProxyError(t *testing.T) {
 	proxyInstance := proxy.NewProxy(&HTTPClientMock{
 		Err: errors.New("dummy error"),
@@ -30,13 +41,34 @@ func TestHttpProxyError(t *testing.T) {
 		t.Fatalf("failed to create request %s", err)
 	}
 
-	_, err = proxyInstance.Forward(req, "api.domain.com", "https")
+	_, err = proxyInstance.Forward(req, newUpstream("api.domain.com", "https"))
 
 	if !errors.Is(err, proxy.ErrFailedToForward) {
 		t.Fatalf("expected to get errFailedToForward err got '%s' instead", err)
 	}
 }
 
+func TestHttpProxyNoTarget(t *testing.T) {
+	proxyInstance := proxy.NewProxy(&HTTPClientMock{
+		Resp: &http.Response{},
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "http://localhost:8000/api", strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("failed to create request %s", err)
+	}
+
+	_, err = proxyInstance.Forward(req, &proxy.Upstream{
+		Name:     "empty",
+		Scheme:   "https",
+		Balancer: proxy.NewRoundRobinBalancer(nil),
+	})
+
+	if !errors.Is(err, proxy.ErrNoTarget) {
+		t.Fatalf("expected to get ErrNoTarget err got '%s' instead", err)
+	}
+}
+
 func TestHttpProxySuccess(t *testing.T) {
 	clientMock := &HTTPClientMock{
 		Resp: &http.Response{},
@@ -51,7 +83,9 @@ func TestHttpProxySuccess(t *testing.T) {
 		t.Fatalf("failed to create request %s", err)
 	}
 
-	resp, err := proxyInstance.Forward(req, forwardHost, forwardScheme)
+	upstream := newUpstream(forwardHost, forwardScheme)
+
+	resp, err := proxyInstance.Forward(req, upstream)
 
 	if err != nil {
 		t.Fatalf("expected success got err instead %s", err)
@@ -64,4 +98,20 @@ func TestHttpProxySuccess(t *testing.T) {
 	if clientMock.Req.URL.Host != forwardHost {
 		t.Fatalf("expected to replace host on forwarding request  got %s instead", clientMock.Req.URL.Host)
 	}
+
+	if clientMock.Req.URL.Scheme != forwardScheme {
+		t.Fatalf("expected to replace scheme on forwarding request got %s instead", clientMock.Req.URL.Scheme)
+	}
+
+	target := upstream.Targets[0]
+	if target.Outstanding() != 1 {
+		t.Fatalf("expected 1 outstanding request before closing body got %d instead", target.Outstanding())
+	}
+
+	resp.Body.Close()
+	resp.Body.Close()
+
+	if target.Outstanding() != 0 {
+		t.Fatalf("expected 0 outstanding requests after closing body got %d instead", target.Outstanding())
+	}
 }
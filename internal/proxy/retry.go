@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/vjerci/reverse-proxy/internal/log"
+)
+
+var ErrReplayBody = errors.New("failed to replay request body")
+
+const DefaultRetryInitialBackoff = 100 * time.Millisecond
+const DefaultRetryMaxBackoff = 2 * time.Second
+
+var DefaultRetryOnStatus = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// RetryPolicy retries failed forwarding with exponential backoff and jitter,
+// only idempotent requests are retried unless RetryNonIdempotent is set
+type RetryPolicy struct {
+	MaxAttempts        int
+	InitialBackoff     time.Duration
+	MaxBackoff         time.Duration
+	RetryOnStatus      []int
+	RetryNonIdempotent bool
+}
+
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// RetriesMethod tells whether requests with method may be retried, their body has to be buffered to be replayed
+func (policy *RetryPolicy) RetriesMethod(method string) bool {
+	if policy == nil || policy.MaxAttempts <= 1 {
+		return false
+	}
+
+	return policy.RetryNonIdempotent || idempotentMethods[method]
+}
+
+func (policy *RetryPolicy) allows(req *http.Request) bool {
+	if !policy.RetriesMethod(req.Method) {
+		return false
+	}
+
+	// body that can't be replayed can be sent only once
+	hasBody := req.Body != nil && req.Body != http.NoBody
+
+	return !hasBody || req.GetBody != nil
+}
+
+func (policy *RetryPolicy) shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, ErrNoTarget)
+	}
+
+	retryOnStatus := policy.RetryOnStatus
+	if retryOnStatus == nil {
+		retryOnStatus = DefaultRetryOnStatus
+	}
+
+	for _, status := range retryOnStatus {
+		if resp.StatusCode == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (policy *RetryPolicy) backoff(attempt int) time.Duration {
+	initial := policy.InitialBackoff
+	if initial <= 0 {
+		initial = DefaultRetryInitialBackoff
+	}
+
+	maxBackoff := policy.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultRetryMaxBackoff
+	}
+
+	backoff := initial << (attempt - 1)
+	if backoff > maxBackoff || backoff <= 0 {
+		backoff = maxBackoff
+	}
+
+	// equal jitter, keep half of backoff and randomize the other half
+	half := backoff / 2
+
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+type RetryProxy struct {
+	next   Proxy
+	logger log.Logger
+}
+
+func NewRetryProxy(next Proxy, logger log.Logger) Proxy {
+	return &RetryProxy{
+		next:   next,
+		logger: logger,
+	}
+}
+
+func (proxy *RetryProxy) Forward(req *http.Request, upstream *Upstream) (*http.Response, error) {
+	policy := upstream.RetryPolicy
+	if !policy.allows(req) {
+		return proxy.next.Forward(req, upstream)
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrReplayBody, err)
+			}
+
+			req.Body = body
+		}
+
+		resp, err := proxy.next.Forward(req, upstream)
+
+		retry := attempt < policy.MaxAttempts && policy.shouldRetry(resp, err) && req.Context().Err() == nil
+		proxy.logAttempt(req, upstream, attempt, policy.MaxAttempts, resp, err, retry)
+
+		if !retry {
+			return resp, err
+		}
+
+		if resp != nil && resp.Body != nil {
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w: %w", ErrFailedToForward, req.Context().Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func (proxy *RetryProxy) logAttempt(req *http.Request, upstream *Upstream, attempt int, maxAttempts int, resp *http.Response, err error, retry bool) {
+	outcome := "error: " + fmt.Sprint(err)
+	if err == nil {
+		outcome = fmt.Sprintf("status: %d", resp.StatusCode)
+	}
+
+	proxy.logger.Print(fmt.Sprintf("upstream %s attempt %d/%d %s %s %s, retrying: %t", upstream.Name, attempt, maxAttempts, req.Method, req.URL.String(), outcome, retry))
+}
@@ -0,0 +1,203 @@
+package proxy_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vjerci/reverse-proxy/internal/proxy"
+)
+
+type LoggerMock struct {
+	Lines []string
+}
+
+func (logger *LoggerMock) Print(data ...any) {
+	logger.Lines = append(logger.Lines, data[0].(string))
+}
+
+type ProxyFuncMock struct {
+	method func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error)
+}
+
+func (proxy *ProxyFuncMock) Forward(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+	return proxy.method(req, upstream)
+}
+
+func newRetryRequest(method string, body string) *http.Request {
+	req := httptest.NewRequest(method, "http://localhost/api", strings.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(body)), nil
+	}
+
+	return req
+}
+
+func TestRetryProxy(t *testing.T) {
+	attempts := 0
+	bodies := []string{}
+
+	next := &ProxyFuncMock{
+		method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+			attempts++
+
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatalf("failed to read request body %s", err)
+			}
+			bodies = append(bodies, string(body))
+
+			switch attempts {
+			case 1:
+				return nil, errors.New("connection refused")
+			case 2:
+				return &http.Response{
+					StatusCode: http.StatusServiceUnavailable,
+					Body:       io.NopCloser(bytes.NewReader(nil)),
+				}, nil
+			}
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		},
+	}
+
+	logger := &LoggerMock{}
+	retryProxy := proxy.NewRetryProxy(next, logger)
+
+	resp, err := retryProxy.Forward(newRetryRequest(http.MethodPut, "body"), &proxy.Upstream{
+		Name: "api",
+		RetryPolicy: &proxy.RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     2 * time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected success after retries got %s instead", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 got %d instead", resp.StatusCode)
+	}
+
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts got %d instead", attempts)
+	}
+
+	for _, body := range bodies {
+		if body != "body" {
+			t.Fatalf("expected request body to be replayed on each attempt got %v instead", bodies)
+		}
+	}
+
+	if len(logger.Lines) != 3 {
+		t.Fatalf("expected each attempt to be logged got %v instead", logger.Lines)
+	}
+
+	if !strings.Contains(logger.Lines[1], "attempt 2/3") || !strings.Contains(logger.Lines[1], "status: 503") {
+		t.Fatalf("expected second attempt log to contain attempt number and status got %s instead", logger.Lines[1])
+	}
+}
+
+func TestRetryProxyGivesUp(t *testing.T) {
+	attempts := 0
+
+	next := &ProxyFuncMock{
+		method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+			attempts++
+			return &http.Response{
+				StatusCode: http.StatusBadGateway,
+				Body:       io.NopCloser(bytes.NewReader(nil)),
+			}, nil
+		},
+	}
+
+	retryProxy := proxy.NewRetryProxy(next, &LoggerMock{})
+
+	resp, err := retryProxy.Forward(newRetryRequest(http.MethodGet, ""), &proxy.Upstream{
+		Name: "api",
+		RetryPolicy: &proxy.RetryPolicy{
+			MaxAttempts:    2,
+			InitialBackoff: time.Millisecond,
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected last response to be returned got %s instead", err)
+	}
+
+	if resp.StatusCode != http.StatusBadGateway || attempts != 2 {
+		t.Fatalf("expected 2 attempts ending with 502 got %d attempts and %d status", attempts, resp.StatusCode)
+	}
+}
+
+func TestRetryProxyNoRetry(t *testing.T) {
+	testCases := []struct {
+		testName string
+		req      *http.Request
+		policy   *proxy.RetryPolicy
+	}{
+		{
+			testName: "no_policy",
+			req:      newRetryRequest(http.MethodGet, ""),
+			policy:   nil,
+		},
+		{
+			testName: "non_idempotent_method",
+			req:      newRetryRequest(http.MethodPost, "body"),
+			policy: &proxy.RetryPolicy{
+				MaxAttempts: 3,
+			},
+		},
+		{
+			testName: "body_cant_be_replayed",
+			req:      httptest.NewRequest(http.MethodPut, "http://localhost/api", strings.NewReader("body")),
+			policy: &proxy.RetryPolicy{
+				MaxAttempts: 3,
+			},
+		},
+		{
+			testName: "status_not_retried",
+			req:      newRetryRequest(http.MethodGet, ""),
+			policy: &proxy.RetryPolicy{
+				MaxAttempts:   3,
+				RetryOnStatus: []int{http.StatusBadGateway},
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		attempts := 0
+
+		next := &ProxyFuncMock{
+			method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+				attempts++
+				return &http.Response{
+					StatusCode: http.StatusServiceUnavailable,
+					Body:       io.NopCloser(bytes.NewReader(nil)),
+				}, nil
+			},
+		}
+
+		retryProxy := proxy.NewRetryProxy(next, &LoggerMock{})
+
+		_, err := retryProxy.Forward(test.req, &proxy.Upstream{
+			Name:        "api",
+			RetryPolicy: test.policy,
+		})
+		if err != nil {
+			t.Fatalf("for test %s got err %s", test.testName, err)
+		}
+
+		if attempts != 1 {
+			t.Fatalf("for test %s expected single attempt got %d instead", test.testName, attempts)
+		}
+	}
+}
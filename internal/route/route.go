@@ -0,0 +1,157 @@
+package route
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/vjerci/reverse-proxy/internal/mask"
+	"github.com/vjerci/reverse-proxy/internal/proxy"
+)
+
+var ErrNoRoute = errors.New("no route matches request")
+var ErrUnknownUpstream = errors.New("route references unknown upstream")
+
+type Router interface {
+	// Route picks the upstream for req and rewrites its path according to the matched route
+	Route(req *http.Request) (*Match, error)
+}
+
+type Match struct {
+	Upstream *proxy.Upstream
+	// MaskDirection overrides masking direction for requests of the route when it isn't empty
+	MaskDirection mask.Direction
+}
+
+// Route matches requests by host header and path prefix, empty fields match anything.
+// Host can be a wildcard like "*.domain.com".
+type Route struct {
+	Host          string
+	PathPrefix    string
+	StripPrefix   bool
+	RewritePrefix string
+	Upstream      string
+	MaskDirection mask.Direction
+}
+
+type RouterInstance struct {
+	routes    []Route
+	upstreams map[string]*proxy.Upstream
+}
+
+// routes are matched in the order they are declared, first match wins
+func NewRouter(routes []Route, upstreams map[string]*proxy.Upstream) (Router, error) {
+	for _, route := range routes {
+		if _, ok := upstreams[route.Upstream]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownUpstream, route.Upstream)
+		}
+	}
+
+	return &RouterInstance{
+		routes:    routes,
+		upstreams: upstreams,
+	}, nil
+}
+
+func (router *RouterInstance) Route(req *http.Request) (*Match, error) {
+	for _, route := range router.routes {
+		if !route.matchesHost(req.Host) || !route.matchesPath(req.URL.EscapedPath()) {
+			continue
+		}
+
+		route.rewritePath(req)
+
+		return &Match{
+			Upstream:      router.upstreams[route.Upstream],
+			MaskDirection: route.MaskDirection,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s%s", ErrNoRoute, req.Host, req.URL.Path)
+}
+
+func (route *Route) matchesHost(requestHost string) bool {
+	if route.Host == "" {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(requestHost)
+	if err != nil {
+		host = requestHost
+	}
+
+	if strings.HasPrefix(route.Host, "*.") {
+		return len(host) > len(route.Host)-1 && strings.EqualFold(host[len(host)-len(route.Host)+1:], route.Host[1:])
+	}
+
+	return strings.EqualFold(host, route.Host)
+}
+
+// path prefix matches on segment boundaries so "/api" and "/api/" match "/api/users" but not "/apiv2".
+// Escaped path is split into segments before they are decoded, the same way it is rewritten,
+// so "/api%2Fadmin" is a single segment which doesn't match "/api/admin"
+func (route *Route) matchesPath(escapedPath string) bool {
+	prefix := strings.TrimSuffix(route.PathPrefix, "/")
+	if prefix == "" {
+		return true
+	}
+
+	prefixSegments := strings.Split(prefix, "/")
+	segments := strings.Split(escapedPath, "/")
+
+	if len(segments) < len(prefixSegments) {
+		return false
+	}
+
+	for i, prefixSegment := range prefixSegments {
+		segment, err := url.PathUnescape(segments[i])
+		if err != nil || segment != prefixSegment {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (route *Route) rewritePath(req *http.Request) {
+	if route.PathPrefix == "" || (!route.StripPrefix && route.RewritePrefix == "") {
+		return
+	}
+
+	prefix := strings.TrimSuffix(route.PathPrefix, "/")
+	rewritePrefix := strings.TrimSuffix(route.RewritePrefix, "/")
+
+	// escaped path is rewritten alongside decoded one, so encoded characters like %2F keep their meaning upstream
+	path := rewritePrefix + strings.TrimPrefix(req.URL.Path, prefix)
+	rawPath := (&url.URL{Path: rewritePrefix}).EscapedPath() + escapedSuffix(req.URL.EscapedPath(), len(prefix))
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+		rawPath = "/" + rawPath
+	}
+
+	req.URL.Path = path
+	req.URL.RawPath = rawPath
+}
+
+// escapedSuffix returns part of escaped path following its first n decoded bytes
+func escapedSuffix(escaped string, n int) string {
+	i := 0
+
+	for decoded := 0; decoded < n && i < len(escaped); decoded++ {
+		if escaped[i] == '%' {
+			i += 3
+		} else {
+			i++
+		}
+	}
+
+	if i > len(escaped) {
+		return ""
+	}
+
+	return escaped[i:]
+}
@@ -0,0 +1,213 @@
+package route_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vjerci/reverse-proxy/internal/mask"
+	"github.com/vjerci/reverse-proxy/internal/proxy"
+	"github.com/vjerci/reverse-proxy/internal/route"
+)
+
+func TestRouter(t *testing.T) {
+	upstreams := map[string]*proxy.Upstream{
+		"users": {
+			Name:   "users",
+			Scheme: "http",
+		},
+		"orders": {
+			Name:   "orders",
+			Scheme: "http",
+		},
+		"tenants": {
+			Name:   "tenants",
+			Scheme: "http",
+		},
+		"default": {
+			Name:   "default",
+			Scheme: "http",
+		},
+	}
+
+	router, err := route.NewRouter([]route.Route{
+		{
+			Host:       "api.domain.com",
+			PathPrefix: "/users",
+			Upstream:   "users",
+		},
+		{
+			PathPrefix:    "/orders/",
+			StripPrefix:   true,
+			Upstream:      "orders",
+			MaskDirection: mask.DirectionBoth,
+		},
+		{
+			PathPrefix:    "/legacy",
+			RewritePrefix: "/v2",
+			Upstream:      "orders",
+		},
+		{
+			Host:     "*.tenants.com",
+			Upstream: "tenants",
+		},
+		{
+			Host:     "domain.com",
+			Upstream: "default",
+		},
+	}, upstreams)
+	if err != nil {
+		t.Fatalf("failed to create router %s", err)
+	}
+
+	testCases := []struct {
+		testName            string
+		url                 string
+		expectedUpstream    string
+		expectedPath        string
+		expectedEscapedPath string
+		expectedDirection   mask.Direction
+	}{
+		{
+			testName:         "host_and_path",
+			url:              "http://api.domain.com:8000/users/1",
+			expectedUpstream: "users",
+			expectedPath:     "/users/1",
+		},
+		{
+			testName:          "strip_prefix",
+			url:               "http://api.domain.com/orders/1",
+			expectedUpstream:  "orders",
+			expectedPath:      "/1",
+			expectedDirection: mask.DirectionBoth,
+		},
+		{
+			testName:          "strip_whole_path",
+			url:               "http://api.domain.com/orders",
+			expectedUpstream:  "orders",
+			expectedPath:      "/",
+			expectedDirection: mask.DirectionBoth,
+		},
+		{
+			testName:         "rewrite_prefix",
+			url:              "http://api.domain.com/legacy/orders",
+			expectedUpstream: "orders",
+			expectedPath:     "/v2/orders",
+		},
+		{
+			testName:            "strip_prefix_keeps_encoded_slash",
+			url:                 "http://api.domain.com/orders/a%2Fb",
+			expectedUpstream:    "orders",
+			expectedPath:        "/a/b",
+			expectedEscapedPath: "/a%2Fb",
+			expectedDirection:   mask.DirectionBoth,
+		},
+		{
+			testName:            "rewrite_prefix_keeps_encoded_slash",
+			url:                 "http://api.domain.com/legacy/files/a%2Fb%20c",
+			expectedUpstream:    "orders",
+			expectedPath:        "/v2/files/a/b c",
+			expectedEscapedPath: "/v2/files/a%2Fb%20c",
+		},
+		{
+			testName:            "encoded_prefix",
+			url:                 "http://api.domain.com/%6Frders/a%2Fb",
+			expectedUpstream:    "orders",
+			expectedPath:        "/a/b",
+			expectedEscapedPath: "/a%2Fb",
+			expectedDirection:   mask.DirectionBoth,
+		},
+		{
+			testName:         "wildcard_host",
+			url:              "http://acme.tenants.com/legacyorders",
+			expectedUpstream: "tenants",
+			expectedPath:     "/legacyorders",
+		},
+		{
+			testName:         "case_insensitive_host",
+			url:              "http://DOMAIN.com/users",
+			expectedUpstream: "default",
+			expectedPath:     "/users",
+		},
+	}
+
+	for _, test := range testCases {
+		req := httptest.NewRequest(http.MethodGet, test.url, http.NoBody)
+
+		match, err := router.Route(req)
+		if err != nil {
+			t.Fatalf("for test %s got err %s", test.testName, err)
+		}
+
+		if match.MaskDirection != test.expectedDirection {
+			t.Fatalf("for test %s expected mask direction %s got %s instead", test.testName, test.expectedDirection, match.MaskDirection)
+		}
+
+		upstream := match.Upstream
+		if upstream.Name != test.expectedUpstream {
+			t.Fatalf("for test %s expected upstream %s got %s instead", test.testName, test.expectedUpstream, upstream.Name)
+		}
+
+		if req.URL.Path != test.expectedPath {
+			t.Fatalf("for test %s expected path %s got %s instead", test.testName, test.expectedPath, req.URL.Path)
+		}
+
+		if test.expectedEscapedPath != "" && req.URL.EscapedPath() != test.expectedEscapedPath {
+			t.Fatalf("for test %s expected escaped path %s got %s instead", test.testName, test.expectedEscapedPath, req.URL.EscapedPath())
+		}
+	}
+}
+
+func TestRouterNoRoute(t *testing.T) {
+	router, err := route.NewRouter([]route.Route{
+		{
+			PathPrefix: "/api",
+			Upstream:   "api",
+		},
+		{
+			PathPrefix: "/admin/panel",
+			Upstream:   "api",
+		},
+		{
+			Host:     "*.domain.com",
+			Upstream: "api",
+		},
+	}, map[string]*proxy.Upstream{
+		"api": {
+			Name:   "api",
+			Scheme: "http",
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create router %s", err)
+	}
+
+	for _, url := range []string{"http://localhost/apiv2", "http://localhost/api%2Fv2", "http://localhost/admin%2Fpanel", "http://domain.com/", "http://localhost/"} {
+		match, err := router.Route(httptest.NewRequest(http.MethodGet, url, http.NoBody))
+
+		if match != nil {
+			t.Fatalf("for url %s expected nil match got %#v instead", url, match)
+		}
+
+		if !errors.Is(err, route.ErrNoRoute) {
+			t.Fatalf("for url %s expected ErrNoRoute got %s instead", url, err)
+		}
+	}
+}
+
+func TestRouterUnknownUpstream(t *testing.T) {
+	router, err := route.NewRouter([]route.Route{
+		{
+			Upstream: "missing",
+		},
+	}, map[string]*proxy.Upstream{})
+
+	if router != nil {
+		t.Fatalf("expected nil router got %#v instead", router)
+	}
+
+	if !errors.Is(err, route.ErrUnknownUpstream) {
+		t.Fatalf("expected ErrUnknownUpstream got %s instead", err)
+	}
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+const encodingGzip = "gzip"
+const encodingXGzip = "x-gzip"
+const encodingDeflate = "deflate"
+const encodingBrotli = "br"
+const encodingIdentity = "identity"
+const encodingAny = "*"
+
+// supportedEncodings are content codings proxy can decode for inspection and encode again in order of preference,
+// zstd isn't supported yet
+var supportedEncodings = []string{encodingGzip, encodingBrotli, encodingDeflate}
+
+// decodeBody returns reader of decoded body, identity or empty encoding returns body as it is.
+// Encoded body which turns out to be empty, like chunked response without chunks, is returned as http.NoBody
+// since decoders fail reading header it doesn't have
+func decodeBody(contentEncoding string, body io.Reader) (io.Reader, error) {
+	encoding := strings.ToLower(strings.TrimSpace(contentEncoding))
+	if encoding == "" || encoding == encodingIdentity {
+		return body, nil
+	}
+
+	buffered := bufio.NewReader(body)
+
+	_, err := buffered.Peek(1)
+	if err == io.EOF {
+		return http.NoBody, nil
+	}
+
+	switch encoding {
+	case encodingGzip, encodingXGzip:
+		return gzip.NewReader(buffered)
+	case encodingDeflate:
+		// deflate is meant to be zlib wrapped, but some servers send raw deflate stream
+		header, err := buffered.Peek(2)
+		if err == nil && isZlibHeader(header) {
+			return zlib.NewReader(buffered)
+		}
+
+		return flate.NewReader(buffered), nil
+	case encodingBrotli:
+		return brotli.NewReader(buffered), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", errUnsupportedEncoding, contentEncoding)
+}
+
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func newEncoder(encoding string, output io.Writer) encoder {
+	switch encoding {
+	case encodingDeflate:
+		return zlib.NewWriter(output)
+	case encodingBrotli:
+		return brotli.NewWriter(output)
+	}
+
+	return gzip.NewWriter(output)
+}
+
+// acceptedEncodings are weights of content codings from Accept-Encoding header
+type acceptedEncodings map[string]float64
+
+func parseAcceptEncoding(header string) acceptedEncodings {
+	accepted := make(acceptedEncodings)
+
+	for _, item := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(item, ";")
+
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		weight := 1.0
+
+		name, value, _ := strings.Cut(params, "=")
+		if strings.TrimSpace(name) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err == nil {
+				weight = parsed
+			}
+		}
+
+		accepted[coding] = weight
+	}
+
+	return accepted
+}
+
+// weight returns how much client wants the coding, codings not listed are covered by "*",
+// identity is acceptable unless it is refused explicitly
+func (accepted acceptedEncodings) weight(coding string) float64 {
+	if coding == encodingXGzip {
+		coding = encodingGzip
+	}
+
+	weight, ok := accepted[coding]
+	if ok {
+		return weight
+	}
+
+	weight, ok = accepted[encodingAny]
+	if ok {
+		return weight
+	}
+
+	if coding == encodingIdentity {
+		return 1
+	}
+
+	return 0
+}
+
+// filterAcceptEncoding keeps in Accept-Encoding only codings proxy can decode, so upstream doesn't answer masked request with zstd
+func filterAcceptEncoding(header string) string {
+	accepted := parseAcceptEncoding(header)
+
+	filtered := make([]string, 0, len(supportedEncodings)+1)
+
+	for _, coding := range []string{encodingGzip, encodingBrotli, encodingDeflate, encodingIdentity} {
+		weight := accepted.weight(coding)
+		if weight <= 0 {
+			continue
+		}
+
+		filtered = append(filtered, coding+";q="+strconv.FormatFloat(weight, 'f', -1, 64))
+	}
+
+	if len(filtered) == 0 {
+		return encodingIdentity
+	}
+
+	return strings.Join(filtered, ", ")
+}
+
+// responseEncoding picks coding masked body is sent to client with, original coding is kept if client accepts it,
+// otherwise supported coding client wants the most is used
+func responseEncoding(original string, acceptEncoding string) string {
+	original = strings.ToLower(strings.TrimSpace(original))
+
+	if acceptEncoding == "" {
+		return original
+	}
+
+	accepted := parseAcceptEncoding(acceptEncoding)
+	if accepted.weight(original) > 0 {
+		return original
+	}
+
+	best := encodingIdentity
+	bestWeight := 0.0
+
+	for _, coding := range supportedEncodings {
+		weight := accepted.weight(coding)
+		if weight > bestWeight {
+			best = coding
+			bestWeight = weight
+		}
+	}
+
+	return best
+}
+
+// encodingResponseWriter compresses body written to client if headers written through it carry its Content-Encoding,
+// so log sees masked body decoded while client gets it compressed. Error responses written without Content-Encoding pass through unchanged
+type encodingResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+	encoder  encoder
+}
+
+func newEncodingResponseWriter(writer http.ResponseWriter, encoding string) *encodingResponseWriter {
+	return &encodingResponseWriter{
+		ResponseWriter: writer,
+		encoding:       encoding,
+	}
+}
+
+func (writer *encodingResponseWriter) WriteHeader(statusCode int) {
+	if writer.Header().Get("Content-Encoding") == writer.encoding {
+		writer.encoder = newEncoder(writer.encoding, writer.ResponseWriter)
+	}
+
+	writer.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (writer *encodingResponseWriter) Write(content []byte) (int, error) {
+	if writer.encoder == nil {
+		return writer.ResponseWriter.Write(content)
+	}
+
+	return writer.encoder.Write(content)
+}
+
+func (writer *encodingResponseWriter) Flush() {
+	if writer.encoder != nil {
+		writer.encoder.Flush()
+	}
+
+	flusher, canFlush := writer.ResponseWriter.(http.Flusher)
+	if canFlush {
+		flusher.Flush()
+	}
+}
+
+// Close writes end of compressed body
+func (writer *encodingResponseWriter) Close() error {
+	if writer.encoder == nil {
+		return nil
+	}
+
+	return writer.encoder.Close()
+}
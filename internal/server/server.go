@@ -1,59 +1,262 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/vjerci/reverse-proxy/internal/block"
 	"github.com/vjerci/reverse-proxy/internal/log"
 	"github.com/vjerci/reverse-proxy/internal/mask"
 	"github.com/vjerci/reverse-proxy/internal/proxy"
+	"github.com/vjerci/reverse-proxy/internal/route"
 )
 
 var ProxyErrorBlock = []byte("proxy config blocks this request")
+var ProxyErrorRateLimited = []byte("proxy rate limit for this request is exceeded")
+var ProxyErrorNoRoute = []byte("proxy has no route for this request")
 var ProxyErrorForwardingRequest = []byte("proxy failed to forward request and get response")
+var ProxyErrorCircuitOpen = []byte("proxy circuit breaker for upstream is open")
 var ProxyErrorReadingResponseBody = []byte("proxy failed to read forwarded response body")
 var ProxyErrorReadingRequestBody = []byte("proxy failed to read request body")
+var ProxyErrorRequestTooLarge = []byte("proxy can't buffer request body this large")
+var ProxyErrorResponseTooLarge = []byte("proxy can't inspect forwarded response body this large")
 var ProxyErrorInspectingRequest = []byte("proxy failed to inspect forwarded response body")
+var ProxyErrorInspectingRequestBody = []byte("proxy failed to inspect request body")
+var ProxyErrorUnknownRequestContent = []byte("proxy can't mask request body of this content type")
+var ProxyErrorUnknownResponseContent = []byte("proxy can't mask forwarded response body of this content type")
+var ProxyErrorUnsupportedEncoding = []byte("proxy can't decode forwarded response body encoding")
+
+var errBodyTooLarge = errors.New("body exceeds buffer limit")
 
 const ProxyResponseHeader = "X-Proxy-Error"
 const ProxyResponseHeaderError = "true"
 const ProxyResponseHeaderSuccess = "false"
 
-func Handle(inspector mask.Inspector, responseWriterFactory log.ResponseWriterFactory, guard block.Guard, proxy proxy.Proxy, forwardHost string, forwardScheme string) func(w http.ResponseWriter, req *http.Request) {
+const DefaultMaxRequestBody = 10 << 20
+const DefaultMaxResponseBody = 10 << 20
+
+// BufferLimits caps how much of a body proxy keeps in memory.
+// Request bodies are buffered only when guards or log need them, otherwise they are streamed upstream.
+// Response bodies are buffered only when they are inspected by inspector which can't stream, otherwise they are streamed to client.
+type BufferLimits struct {
+	MaxRequestBody  int64
+	MaxResponseBody int64
+}
+
+func (limits BufferLimits) maxRequestBody() int64 {
+	if limits.MaxRequestBody <= 0 {
+		return DefaultMaxRequestBody
+	}
+
+	return limits.MaxRequestBody
+}
+
+func (limits BufferLimits) maxResponseBody() int64 {
+	if limits.MaxResponseBody <= 0 {
+		return DefaultMaxResponseBody
+	}
+
+	return limits.MaxResponseBody
+}
+
+// MaskingPolicy decides which bodies of a request are masked, direction of matched route wins over direction of request method
+// and direction of request method wins over Default.
+// Unknown decides what happens to bodies which should be masked but have no inspector, Logger reports bodies passed by UnknownContentLog
+type MaskingPolicy struct {
+	Default mask.Direction
+	Methods map[string]mask.Direction
+	Unknown mask.UnknownContentPolicy
+	Logger  log.Logger
+}
+
+// DefaultMaskingPolicy masks only responses of GET requests and passes bodies without inspector unmasked
+func DefaultMaskingPolicy() MaskingPolicy {
+	return MaskingPolicy{
+		Default: mask.DirectionNone,
+		Methods: map[string]mask.Direction{
+			http.MethodGet: mask.DirectionResponse,
+		},
+		Unknown: mask.UnknownContentPass,
+	}
+}
+
+func (policy MaskingPolicy) direction(req *http.Request, match *route.Match) mask.Direction {
+	if match.MaskDirection != "" {
+		return match.MaskDirection
+	}
+
+	direction, ok := policy.Methods[req.Method]
+	if ok {
+		return direction
+	}
+
+	return policy.Default
+}
+
+// passesUnknown tells whether body of content type without inspector can be forwarded unmasked
+func (policy MaskingPolicy) passesUnknown(body string, req *http.Request, contentType string) bool {
+	switch policy.Unknown {
+	case mask.UnknownContentBlock:
+		return false
+	case mask.UnknownContentLog:
+		if policy.Logger != nil {
+			policy.Logger.Print(fmt.Sprintf("%s body of %s %s with content type %q passed unmasked, there is no inspector for it", body, req.Method, req.URL.String(), contentType))
+		}
+	}
+
+	return true
+}
+
+// inspectors are looked up by content type of body they mask, bodies without inspector are handled by masking policy
+func Handle(inspectors *mask.Registry, responseWriterFactory log.ResponseWriterFactory, guard block.Guard, router route.Router, proxyInstance proxy.Proxy, limits BufferLimits, masking MaskingPolicy) func(w http.ResponseWriter, req *http.Request) {
+	bufferRequestBody := block.NeedsBody(guard) || responseWriterFactory.NeedsRequestBody()
+
 	return func(w http.ResponseWriter, req *http.Request) {
 		defer req.Body.Close()
 
-		reqBody, err := io.ReadAll(req.Body)
+		var reqBody []byte
+
+		if bufferRequestBody {
+			var err error
+
+			reqBody, err = bufferBody(req, limits.maxRequestBody())
+			if err != nil {
+				writeBufferError(responseWriterFactory.New(req, nil, w), err)
+				return
+			}
+		}
+
+		respWithLog := responseWriterFactory.New(req, reqBody, w)
+
+		req, verdict := block.WithVerdict(req)
+
+		if guard.ShouldBlock(req) {
+			writeBlocked(respWithLog, verdict)
+			return
+		}
+
+		match, err := router.Route(req)
 		if err != nil {
-			respWithLog := responseWriterFactory.New(req, nil, w)
-			respWithLog.Write(http.StatusInternalServerError, map[string][]string{
+			respWithLog.Write(http.StatusNotFound, map[string][]string{
 				ProxyResponseHeader: {ProxyResponseHeaderError},
-			}, ProxyErrorReadingRequestBody)
+			}, ProxyErrorNoRoute)
 			return
 		}
 
-		respWithLog := responseWriterFactory.New(req, nil, w)
-		req.Body = io.NopCloser(bytes.NewBuffer(reqBody))
+		// body of request which may be retried is buffered, so it can be replayed
+		buffered := bufferRequestBody
+		if !buffered && req.ContentLength != 0 && match.Upstream.RetryPolicy.RetriesMethod(req.Method) {
+			reqBody, err = bufferBody(req, limits.maxRequestBody())
+			if err != nil {
+				writeBufferError(respWithLog, err)
+				return
+			}
+
+			buffered = true
+		}
+
+		direction := masking.direction(req, match)
 
-		if guard.ShouldBlock(req) {
-			respWithLog.Write(http.StatusForbidden, map[string][]string{
+		requestContentType := req.Header.Get("Content-Type")
+
+		requestInspector, ok := inspectors.Lookup(requestContentType)
+		if direction.MasksRequest() && !ok && req.ContentLength != 0 && !masking.passesUnknown("request", req, requestContentType) {
+			respWithLog.Write(http.StatusUnsupportedMediaType, map[string][]string{
+				ProxyResponseHeader: {ProxyResponseHeaderError},
+			}, ProxyErrorUnknownRequestContent)
+			return
+		}
+
+		if direction.MasksRequest() && ok {
+			if !buffered {
+				reqBody, err = bufferBody(req, limits.maxRequestBody())
+				if err != nil {
+					writeBufferError(respWithLog, err)
+					return
+				}
+			}
+
+			if len(reqBody) > 0 {
+				reqBody, err = requestInspector.Inspect(reqBody)
+				if err != nil {
+					respWithLog.Write(http.StatusBadRequest, map[string][]string{
+						ProxyResponseHeader: {ProxyResponseHeaderError},
+					}, ProxyErrorInspectingRequestBody)
+					return
+				}
+
+				replaceBody(req, reqBody)
+
+				// log masked request body instead of original one
+				if bufferRequestBody {
+					respWithLog = responseWriterFactory.New(req, reqBody, w)
+				}
+			}
+		}
+
+		acceptEncoding := strings.Join(req.Header.Values("Accept-Encoding"), ",")
+		if direction.MasksResponse() && acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", filterAcceptEncoding(acceptEncoding))
+		}
+
+		proxyResp, err := proxyInstance.Forward(req, match.Upstream)
+		if errors.Is(err, proxy.ErrCircuitOpen) {
+			respWithLog.Write(http.StatusServiceUnavailable, map[string][]string{
 				ProxyResponseHeader: {ProxyResponseHeaderError},
-			}, ProxyErrorBlock)
+			}, ProxyErrorCircuitOpen)
 			return
 		}
 
-		proxyResp, err := proxy.Forward(req, forwardHost, forwardScheme)
 		if err != nil {
 			respWithLog.Write(http.StatusInternalServerError, map[string][]string{
 				ProxyResponseHeader: {ProxyResponseHeaderError},
 			}, ProxyErrorForwardingRequest)
 			return
 		}
+		defer proxyResp.Body.Close()
+
+		headers := make(map[string][]string)
+
+		for key, value := range proxyResp.Header {
+			headers[key] = value
+		}
+
+		headers[ProxyResponseHeader] = []string{ProxyResponseHeaderSuccess}
+
+		responseContentType := proxyResp.Header.Get("Content-Type")
+
+		inspector, ok := inspectors.Lookup(responseContentType)
+		if direction.MasksResponse() && !ok && hasResponseBody(req, proxyResp) && !masking.passesUnknown("response", req, responseContentType) {
+			respWithLog.Write(http.StatusBadGateway, map[string][]string{
+				ProxyResponseHeader: {ProxyResponseHeaderError},
+			}, ProxyErrorUnknownResponseContent)
+			return
+		}
+
+		// bodyless responses, like 204 and 304, pass through even when they are labeled with masked content type
+		if !direction.MasksResponse() || !ok || !hasResponseBody(req, proxyResp) {
+			respWithLog.Stream(proxyResp.StatusCode, headers, proxyResp.Body)
+			return
+		}
+
+		// only responses carrying body get here, so empty body of encoded 304 isn't decoded
+		contentEncoding := proxyResp.Header.Get("Content-Encoding")
+
+		body, err := decodeBody(contentEncoding, proxyResp.Body)
+		if errors.Is(err, errUnsupportedEncoding) {
+			respWithLog.Write(http.StatusBadGateway, map[string][]string{
+				ProxyResponseHeader: {ProxyResponseHeaderError},
+			}, ProxyErrorUnsupportedEncoding)
+			return
+		}
 
-		respBytes, err := io.ReadAll(proxyResp.Body)
 		if err != nil {
 			respWithLog.Write(http.StatusInternalServerError, map[string][]string{
 				ProxyResponseHeader: {ProxyResponseHeaderError},
@@ -61,34 +264,161 @@ func Handle(inspector mask.Inspector, responseWriterFactory log.ResponseWriterFa
 			return
 		}
 
-		tamperedRequest := false
+		// empty encoded body has nothing to mask
+		if body == http.NoBody {
+			respWithLog.Stream(proxyResp.StatusCode, headers, body)
+			return
+		}
 
-		if req.Method == http.MethodGet && proxyResp.Header.Get("Content-Type") == "application/json" {
-			maskedJson, err := inspector.Inspect(respBytes)
-			if err != nil {
-				respWithLog.Write(http.StatusInternalServerError, map[string][]string{
-					ProxyResponseHeader: {ProxyResponseHeaderError},
-				}, ProxyErrorInspectingRequest)
-				return
+		// masked body is logged decoded and encoded again only on its way to client
+		encoded := false
+		if body != proxyResp.Body {
+			encoding := responseEncoding(contentEncoding, acceptEncoding)
+
+			delete(headers, "Content-Encoding")
+
+			if encoding != encodingIdentity {
+				encoded = true
+				headers["Content-Encoding"] = []string{encoding}
+
+				encodingWriter := newEncodingResponseWriter(w, encoding)
+				defer encodingWriter.Close()
+
+				respWithLog = responseWriterFactory.New(req, reqBody, encodingWriter)
 			}
+		}
 
-			respBytes = maskedJson
-			tamperedRequest = true
+		streamInspector, canStream := inspector.(mask.StreamInspector)
+		if canStream {
+			streamInspected(respWithLog, streamInspector, proxyResp.StatusCode, body, headers)
+			return
+		}
 
+		respBytes, err := readLimited(body, limits.maxResponseBody())
+		if errors.Is(err, errBodyTooLarge) {
+			respWithLog.Write(http.StatusBadGateway, map[string][]string{
+				ProxyResponseHeader: {ProxyResponseHeaderError},
+			}, ProxyErrorResponseTooLarge)
+			return
 		}
 
-		headers := make(map[string][]string)
+		if err != nil {
+			respWithLog.Write(http.StatusInternalServerError, map[string][]string{
+				ProxyResponseHeader: {ProxyResponseHeaderError},
+			}, ProxyErrorReadingResponseBody)
+			return
+		}
 
-		for key, value := range proxyResp.Header {
-			headers[key] = value
+		maskedJson, err := inspector.Inspect(respBytes)
+		if err != nil {
+			respWithLog.Write(http.StatusInternalServerError, map[string][]string{
+				ProxyResponseHeader: {ProxyResponseHeaderError},
+			}, ProxyErrorInspectingRequest)
+			return
 		}
 
-		if tamperedRequest {
-			headers["Content-Length"] = []string{strconv.Itoa(len(respBytes))}
+		delete(headers, "Content-Length")
+		if !encoded {
+			headers["Content-Length"] = []string{strconv.Itoa(len(maskedJson))}
 		}
 
-		headers[ProxyResponseHeader] = []string{ProxyResponseHeaderSuccess}
+		respWithLog.Write(proxyResp.StatusCode, headers, maskedJson)
+	}
+}
+
+// streamInspected masks response while streaming it to client, inspection errors are reported with error response
+// only if they happen before first chunk of masked body is ready, later ones cut off the response
+func streamInspected(respWithLog log.ResponseWriter, inspector mask.StreamInspector, statusCode int, body io.Reader, headers map[string][]string) {
+	pipeReader, pipeWriter := io.Pipe()
+	// closing reader stops inspection if client goes away
+	defer pipeReader.Close()
+
+	go func() {
+		pipeWriter.CloseWithError(inspector.InspectStream(body, pipeWriter))
+	}()
+
+	masked := bufio.NewReader(pipeReader)
+
+	_, err := masked.Peek(1)
+	if err != nil && err != io.EOF {
+		respWithLog.Write(http.StatusInternalServerError, map[string][]string{
+			ProxyResponseHeader: {ProxyResponseHeaderError},
+		}, ProxyErrorInspectingRequest)
+		return
+	}
+
+	delete(headers, "Content-Length")
+
+	respWithLog.Stream(statusCode, headers, masked)
+}
+
+// hasResponseBody tells whether response may carry body, responses of unknown length are assumed to carry one
+func hasResponseBody(req *http.Request, resp *http.Response) bool {
+	if req.Method == http.MethodHead || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return false
+	}
+
+	return resp.ContentLength != 0
+}
+
+// bufferBody reads request body into memory and makes it replayable for retries
+func bufferBody(req *http.Request, limit int64) ([]byte, error) {
+	body, err := readLimited(req.Body, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	replaceBody(req, body)
+
+	return body, nil
+}
+
+func replaceBody(req *http.Request, body []byte) {
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+}
+
+// writeBlocked answers requests blocked by rate limit with 429 and time client should wait, others with 403
+func writeBlocked(respWithLog log.ResponseWriter, verdict *block.Verdict) {
+	if verdict.RetryAfter > 0 {
+		respWithLog.Write(http.StatusTooManyRequests, map[string][]string{
+			ProxyResponseHeader: {ProxyResponseHeaderError},
+			"Retry-After":       {strconv.FormatInt(int64(math.Ceil(verdict.RetryAfter.Seconds())), 10)},
+		}, ProxyErrorRateLimited)
+		return
+	}
 
-		respWithLog.Write(proxyResp.StatusCode, headers, respBytes)
+	respWithLog.Write(http.StatusForbidden, map[string][]string{
+		ProxyResponseHeader: {ProxyResponseHeaderError},
+	}, ProxyErrorBlock)
+}
+
+func writeBufferError(respWithLog log.ResponseWriter, err error) {
+	if errors.Is(err, errBodyTooLarge) {
+		respWithLog.Write(http.StatusRequestEntityTooLarge, map[string][]string{
+			ProxyResponseHeader: {ProxyResponseHeaderError},
+		}, ProxyErrorRequestTooLarge)
+		return
 	}
+
+	respWithLog.Write(http.StatusInternalServerError, map[string][]string{
+		ProxyResponseHeader: {ProxyResponseHeaderError},
+	}, ProxyErrorReadingRequestBody)
+}
+
+// readLimited reads whole body or fails with errBodyTooLarge once it reads more than limit bytes
+func readLimited(body io.Reader, limit int64) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(content)) > limit {
+		return nil, errBodyTooLarge
+	}
+
+	return content, nil
 }
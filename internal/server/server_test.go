@@ -1,18 +1,25 @@
 package server_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/andybalholm/brotli"
 	"github.com/stretchr/testify/assert"
 	"github.com/vjerci/reverse-proxy/internal/block"
 	"github.com/vjerci/reverse-proxy/internal/log"
 	"github.com/vjerci/reverse-proxy/internal/mask"
 	"github.com/vjerci/reverse-proxy/internal/proxy"
+	"github.com/vjerci/reverse-proxy/internal/route"
 	"github.com/vjerci/reverse-proxy/internal/server"
 )
 
@@ -24,6 +31,15 @@ func (inspector *InspectorMock) Inspect(bytes []byte) ([]byte, error) {
 	return inspector.method(bytes)
 }
 
+func newRegistry(t *testing.T, inspectors map[string]mask.Inspector) *mask.Registry {
+	registry, err := mask.NewRegistryFrom(inspectors)
+	if err != nil {
+		t.Fatalf("failed to create inspector registry %s", err)
+	}
+
+	return registry
+}
+
 type LoggerMock struct{}
 
 func (logger *LoggerMock) Print(...any) {}
@@ -36,12 +52,20 @@ func (guard *GuardMock) ShouldBlock(req *http.Request) bool {
 	return guard.method(req)
 }
 
+type RouterMock struct {
+	method func(req *http.Request) (*route.Match, error)
+}
+
+func (router *RouterMock) Route(req *http.Request) (*route.Match, error) {
+	return router.method(req)
+}
+
 type ProxyMock struct {
-	method func(req *http.Request, host string, scheme string) (*http.Response, error)
+	method func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error)
 }
 
-func (proxy *ProxyMock) Forward(req *http.Request, host string, scheme string) (*http.Response, error) {
-	return proxy.method(req, host, scheme)
+func (proxy *ProxyMock) Forward(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+	return proxy.method(req, upstream)
 }
 
 type BodyErrReaderMock struct{}
@@ -51,8 +75,6 @@ func (body *BodyErrReaderMock) Read(p []byte) (n int, err error) {
 }
 
 func TestHandleErrors(t *testing.T) {
-	const forwardHost = "api.domain.com"
-	const forwardScheme = "https"
 	const url = "http://localhost:8000"
 
 	var errorHeaders = http.Header{}
@@ -62,14 +84,26 @@ func TestHandleErrors(t *testing.T) {
 		"Content-Type": []string{"application/json"},
 	}
 
+	router := &RouterMock{
+		method: func(req *http.Request) (*route.Match, error) {
+			return &route.Match{
+				Upstream: &proxy.Upstream{
+					Name:   "api",
+					Scheme: "https",
+				},
+			}, nil
+		},
+	}
+
 	testCases := []struct {
 		testName        string
 		expectedStatus  int
 		expectedContent []byte
 
-		Inspector             mask.Inspector
+		Inspectors            map[string]mask.Inspector
 		ResponseWriterFactory log.ResponseWriterFactory
 		Guard                 block.Guard
+		Router                route.Router
 		Proxy                 proxy.Proxy
 
 		req  *http.Request
@@ -81,15 +115,34 @@ func TestHandleErrors(t *testing.T) {
 			expectedContent: server.ProxyErrorReadingRequestBody,
 
 			ResponseWriterFactory: &log.ResponseWriterFactoryInstance{
-				Logger: &LoggerMock{},
+				Logger:         &LoggerMock{},
+				LogRequestBody: true,
 			},
-			Inspector: nil,
-			Guard:     nil,
-			Proxy:     nil,
+			Inspectors: nil,
+			Guard:      nil,
+			Router:     nil,
+			Proxy:      nil,
 
 			req:  httptest.NewRequest(http.MethodPost, url, &BodyErrReaderMock{}),
 			resp: *httptest.NewRecorder(),
 		},
+		{
+			testName:        "request_too_large",
+			expectedStatus:  http.StatusRequestEntityTooLarge,
+			expectedContent: server.ProxyErrorRequestTooLarge,
+
+			ResponseWriterFactory: &log.ResponseWriterFactoryInstance{
+				Logger:         &LoggerMock{},
+				LogRequestBody: true,
+			},
+			Inspectors: nil,
+			Guard:      nil,
+			Router:     nil,
+			Proxy:      nil,
+
+			req:  httptest.NewRequest(http.MethodPost, url, strings.NewReader(strings.Repeat("x", 11))),
+			resp: *httptest.NewRecorder(),
+		},
 		{
 			testName:        "config_guard_block",
 			expectedStatus:  http.StatusForbidden,
@@ -103,12 +156,61 @@ func TestHandleErrors(t *testing.T) {
 					return true
 				},
 			},
-			Inspector: nil,
-			Proxy:     nil,
+			Inspectors: nil,
+			Router:     nil,
+			Proxy:      nil,
 
 			req:  httptest.NewRequest(http.MethodPost, url, strings.NewReader("")),
 			resp: *httptest.NewRecorder(),
 		},
+		{
+			testName:        "no_route",
+			expectedStatus:  http.StatusNotFound,
+			expectedContent: server.ProxyErrorNoRoute,
+
+			ResponseWriterFactory: &log.ResponseWriterFactoryInstance{
+				Logger: &LoggerMock{},
+			},
+			Guard: &GuardMock{
+				func(req *http.Request) bool {
+					return false
+				},
+			},
+			Router: &RouterMock{
+				method: func(req *http.Request) (*route.Match, error) {
+					return nil, route.ErrNoRoute
+				},
+			},
+			Inspectors: nil,
+			Proxy:      nil,
+
+			req:  httptest.NewRequest(http.MethodPost, url, strings.NewReader("")),
+			resp: *httptest.NewRecorder(),
+		},
+		{
+			testName:        "circuit_open",
+			expectedStatus:  http.StatusServiceUnavailable,
+			expectedContent: server.ProxyErrorCircuitOpen,
+
+			ResponseWriterFactory: &log.ResponseWriterFactoryInstance{
+				Logger: &LoggerMock{},
+			},
+			Guard: &GuardMock{
+				func(req *http.Request) bool {
+					return false
+				},
+			},
+			Router: router,
+			Proxy: &ProxyMock{
+				method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+					return nil, proxy.ErrCircuitOpen
+				},
+			},
+			Inspectors: nil,
+
+			req:  httptest.NewRequest(http.MethodGet, url, strings.NewReader("")),
+			resp: *httptest.NewRecorder(),
+		},
 		{
 			testName:        "proxy_forward_error",
 			expectedStatus:  http.StatusInternalServerError,
@@ -122,16 +224,53 @@ func TestHandleErrors(t *testing.T) {
 					return false
 				},
 			},
+			Router: router,
 			Proxy: &ProxyMock{
-				method: func(req *http.Request, host string, scheme string) (*http.Response, error) {
+				method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
 					return nil, errors.New("test error")
 				},
 			},
-			Inspector: nil,
+			Inspectors: nil,
 
 			req:  httptest.NewRequest(http.MethodPost, url, strings.NewReader("")),
 			resp: *httptest.NewRecorder(),
 		},
+		{
+			testName:        "response_too_large",
+			expectedStatus:  http.StatusBadGateway,
+			expectedContent: server.ProxyErrorResponseTooLarge,
+
+			ResponseWriterFactory: &log.ResponseWriterFactoryInstance{
+				Logger: &LoggerMock{},
+			},
+			Guard: &GuardMock{
+				func(req *http.Request) bool {
+					return false
+				},
+			},
+			Router: router,
+			Proxy: &ProxyMock{
+				method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+					return &http.Response{
+						StatusCode:    200,
+						ContentLength: -1,
+						Body:          io.NopCloser(strings.NewReader(`{"name": "very long name"}`)),
+						Header:        jsonHeaders,
+					}, nil
+				},
+			},
+			Inspectors: map[string]mask.Inspector{
+				"application/json": &InspectorMock{
+					method: func(bytes []byte) ([]byte, error) {
+						t.Fatal("expected too large response not to be inspected")
+						return nil, nil
+					},
+				},
+			},
+
+			req:  httptest.NewRequest(http.MethodGet, url, strings.NewReader("")),
+			resp: *httptest.NewRecorder(),
+		},
 		{
 			testName:        "inspect_error",
 			expectedStatus:  http.StatusInternalServerError,
@@ -145,18 +284,22 @@ func TestHandleErrors(t *testing.T) {
 					return false
 				},
 			},
+			Router: router,
 			Proxy: &ProxyMock{
-				method: func(req *http.Request, host string, scheme string) (*http.Response, error) {
+				method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
 					return &http.Response{
-						StatusCode: 200,
-						Body:       io.NopCloser(strings.NewReader("{}")),
-						Header:     jsonHeaders,
+						StatusCode:    200,
+						ContentLength: -1,
+						Body:          io.NopCloser(strings.NewReader("{}")),
+						Header:        jsonHeaders,
 					}, nil
 				},
 			},
-			Inspector: &InspectorMock{
-				method: func(bytes []byte) ([]byte, error) {
-					return nil, errors.New("test error")
+			Inspectors: map[string]mask.Inspector{
+				"application/json": &InspectorMock{
+					method: func(bytes []byte) ([]byte, error) {
+						return nil, errors.New("test error")
+					},
 				},
 			},
 
@@ -166,7 +309,10 @@ func TestHandleErrors(t *testing.T) {
 	}
 
 	for _, test := range testCases {
-		handler := server.Handle(test.Inspector, test.ResponseWriterFactory, test.Guard, test.Proxy, forwardHost, forwardScheme)
+		handler := server.Handle(newRegistry(t, test.Inspectors), test.ResponseWriterFactory, test.Guard, test.Router, test.Proxy, server.BufferLimits{
+			MaxRequestBody:  10,
+			MaxResponseBody: 10,
+		}, server.DefaultMaskingPolicy())
 		handler(&test.resp, test.req)
 
 		assert.Equal(t, test.expectedStatus, test.resp.Result().StatusCode, test.testName+" didnt get expected status code")
@@ -183,8 +329,6 @@ func TestHandleErrors(t *testing.T) {
 }
 
 func TestHandleSucces(t *testing.T) {
-	const forwardHost = "api.domain.com"
-	const forwardScheme = "https"
 	const url = "http://localhost:8000"
 
 	var successHeaders = http.Header{}
@@ -198,15 +342,17 @@ func TestHandleSucces(t *testing.T) {
 	responseBodyContent := "{}"
 
 	response := &http.Response{
-		StatusCode: 200,
-		Body:       io.NopCloser(strings.NewReader(responseBodyContent)),
-		Header:     jsonHeaders,
+		StatusCode:    200,
+		ContentLength: -1,
+		Body:          io.NopCloser(strings.NewReader(responseBodyContent)),
+		Header:        jsonHeaders,
 	}
 
 	testCase := struct {
-		Inspector             mask.Inspector
+		Inspectors            map[string]mask.Inspector
 		ResponseWriterFactory log.ResponseWriterFactory
 		Guard                 block.Guard
+		Router                route.Router
 		Proxy                 proxy.Proxy
 
 		req  *http.Request
@@ -220,14 +366,26 @@ func TestHandleSucces(t *testing.T) {
 				return false
 			},
 		},
+		Router: &RouterMock{
+			method: func(req *http.Request) (*route.Match, error) {
+				return &route.Match{
+					Upstream: &proxy.Upstream{
+						Name:   "api",
+						Scheme: "https",
+					},
+				}, nil
+			},
+		},
 		Proxy: &ProxyMock{
-			method: func(req *http.Request, host string, scheme string) (*http.Response, error) {
+			method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
 				return response, nil
 			},
 		},
-		Inspector: &InspectorMock{
-			method: func(bytes []byte) ([]byte, error) {
-				return bytes, nil
+		Inspectors: map[string]mask.Inspector{
+			"application/json": &InspectorMock{
+				method: func(bytes []byte) ([]byte, error) {
+					return bytes, nil
+				},
 			},
 		},
 
@@ -235,7 +393,7 @@ func TestHandleSucces(t *testing.T) {
 		resp: *httptest.NewRecorder(),
 	}
 
-	handler := server.Handle(testCase.Inspector, testCase.ResponseWriterFactory, testCase.Guard, testCase.Proxy, forwardHost, forwardScheme)
+	handler := server.Handle(newRegistry(t, testCase.Inspectors), testCase.ResponseWriterFactory, testCase.Guard, testCase.Router, testCase.Proxy, server.BufferLimits{}, server.DefaultMaskingPolicy())
 	handler(&testCase.resp, testCase.req)
 
 	assert.Equal(t, response.StatusCode, testCase.resp.Result().StatusCode, "didnt get expected status code")
@@ -251,3 +409,1060 @@ func TestHandleSucces(t *testing.T) {
 
 	assert.Equal(t, jsonHeaders, testCase.resp.Header(), "headers are invalid")
 }
+
+func TestHandleStreaming(t *testing.T) {
+	const url = "http://localhost:8000"
+
+	responseBodyContent := strings.Repeat("streamed response ", 1000)
+
+	var forwardedReq *http.Request
+
+	handler := server.Handle(
+		newRegistry(t, map[string]mask.Inspector{
+			"application/json": &InspectorMock{
+				method: func(bytes []byte) ([]byte, error) {
+					t.Fatal("expected streamed response not to be inspected")
+					return nil, nil
+				},
+			},
+		}),
+		&log.ResponseWriterFactoryInstance{
+			Logger: &LoggerMock{},
+		},
+		&GuardMock{
+			func(req *http.Request) bool {
+				return false
+			},
+		},
+		&RouterMock{
+			method: func(req *http.Request) (*route.Match, error) {
+				return &route.Match{
+					Upstream: &proxy.Upstream{
+						Name: "api",
+					},
+				}, nil
+			},
+		},
+		&ProxyMock{
+			method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+				forwardedReq = req
+
+				return &http.Response{
+					StatusCode: http.StatusCreated,
+					Body:       io.NopCloser(strings.NewReader(responseBodyContent)),
+					Header: http.Header{
+						"Content-Type": []string{"application/octet-stream"},
+					},
+				}, nil
+			},
+		},
+		server.BufferLimits{
+			MaxRequestBody:  10,
+			MaxResponseBody: 10,
+		},
+		server.DefaultMaskingPolicy(),
+	)
+
+	requestBodyContent := strings.Repeat("upload ", 100)
+	resp := httptest.NewRecorder()
+
+	handler(resp, httptest.NewRequest(http.MethodPut, url, strings.NewReader(requestBodyContent)))
+
+	assert.Equal(t, http.StatusCreated, resp.Code, "didnt get expected status code")
+
+	assert.Equal(t, responseBodyContent, resp.Body.String(), "expected response bigger than buffer limit to be streamed")
+
+	assert.True(t, resp.Flushed, "expected streamed response to be flushed")
+
+	assert.Nil(t, forwardedReq.GetBody, "expected request body not to be buffered")
+
+	forwardedBody, err := io.ReadAll(forwardedReq.Body)
+	if err != nil {
+		t.Fatalf("failed to read forwarded request body %s", err)
+	}
+
+	assert.Equal(t, requestBodyContent, string(forwardedBody), "expected request body bigger than buffer limit to be streamed upstream")
+}
+
+func TestHandleStreamInspector(t *testing.T) {
+	const url = "http://localhost:8000"
+
+	testCases := []struct {
+		testName         string
+		contentType      string
+		upstreamBody     string
+		expectedStatus   int
+		expectedBody     string
+		expectedErrorHdr string
+	}{
+		{
+			testName:         "masked",
+			contentType:      "application/json",
+			upstreamBody:     `{"name": "mark", "id": 12345678901234567890}`,
+			expectedStatus:   http.StatusOK,
+			expectedBody:     `{"name":"x","id":12345678901234567890}`,
+			expectedErrorHdr: server.ProxyResponseHeaderSuccess,
+		},
+		{
+			testName:         "invalid_json",
+			contentType:      "application/json",
+			upstreamBody:     `{"name": [}`,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedBody:     string(server.ProxyErrorInspectingRequest),
+			expectedErrorHdr: server.ProxyResponseHeaderError,
+		},
+		{
+			testName:         "masked_xml",
+			contentType:      "application/xml",
+			upstreamBody:     `<user id="1"><name>mark</name></user>`,
+			expectedStatus:   http.StatusOK,
+			expectedBody:     `<user id="1"><name>x</name></user>`,
+			expectedErrorHdr: server.ProxyResponseHeaderSuccess,
+		},
+		{
+			testName:         "masked_event_stream",
+			contentType:      "text/event-stream",
+			upstreamBody:     "data: {\"name\": \"mark\"}\n\ndata: [DONE]\n\n",
+			expectedStatus:   http.StatusOK,
+			expectedBody:     "data: {\"name\":\"x\"}\n\ndata: [DONE]\n\n",
+			expectedErrorHdr: server.ProxyResponseHeaderSuccess,
+		},
+		{
+			testName:         "structured_syntax_suffix",
+			contentType:      "application/problem+json; charset=utf-8",
+			upstreamBody:     `{"title": "invalid", "name": "mark"}`,
+			expectedStatus:   http.StatusOK,
+			expectedBody:     `{"title":"invalid","name":"x"}`,
+			expectedErrorHdr: server.ProxyResponseHeaderSuccess,
+		},
+		{
+			testName:         "no_inspector",
+			contentType:      "text/plain",
+			upstreamBody:     `name`,
+			expectedStatus:   http.StatusOK,
+			expectedBody:     `name`,
+			expectedErrorHdr: server.ProxyResponseHeaderSuccess,
+		},
+	}
+
+	for _, test := range testCases {
+		upstreamBody := test.upstreamBody
+		contentType := test.contentType
+
+		handler := server.Handle(
+			newRegistry(t, map[string]mask.Inspector{
+				"application/json": mask.NewJSONInspector(mask.NewJSONMask(), mask.NewPIIClassifier(mask.NewDefaultPIIPatterns())),
+				"application/xml":  mask.NewXMLInspector(mask.NewJSONMask(), mask.NewPIIClassifier(mask.NewDefaultPIIPatterns())),
+				"text/event-stream": mask.NewEventStreamInspector(
+					mask.NewJSONInspector(mask.NewJSONMask(), mask.NewPIIClassifier(mask.NewDefaultPIIPatterns())),
+				),
+			}),
+			&log.ResponseWriterFactoryInstance{
+				Logger: &LoggerMock{},
+			},
+			&GuardMock{
+				func(req *http.Request) bool {
+					return false
+				},
+			},
+			&RouterMock{
+				method: func(req *http.Request) (*route.Match, error) {
+					return &route.Match{
+						Upstream: &proxy.Upstream{
+							Name: "api",
+						},
+					}, nil
+				},
+			},
+			&ProxyMock{
+				method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+					return &http.Response{
+						StatusCode:    http.StatusOK,
+						ContentLength: -1,
+						Body:          io.NopCloser(strings.NewReader(upstreamBody)),
+						Header: http.Header{
+							"Content-Type":   []string{contentType},
+							"Content-Length": []string{strconv.Itoa(len(upstreamBody))},
+						},
+					}, nil
+				},
+			},
+			server.BufferLimits{
+				MaxResponseBody: 10,
+			},
+			server.DefaultMaskingPolicy(),
+		)
+
+		resp := httptest.NewRecorder()
+		handler(resp, httptest.NewRequest(http.MethodGet, url, http.NoBody))
+
+		assert.Equal(t, test.expectedStatus, resp.Code, test.testName+" didnt get expected status code")
+
+		assert.Equal(t, test.expectedBody, resp.Body.String(), test.testName+" didnt get expected body")
+
+		assert.Equal(t, test.expectedErrorHdr, resp.Header().Get(server.ProxyResponseHeader), test.testName+" didnt get expected proxy error header")
+
+		if test.contentType != "text/plain" {
+			assert.Empty(t, resp.Header().Get("Content-Length"), test.testName+" expected upstream content length to be dropped")
+		}
+	}
+}
+
+func TestHandleRequestMasking(t *testing.T) {
+	const url = "http://localhost:8000"
+
+	masking := server.MaskingPolicy{
+		Default: mask.DirectionNone,
+		Methods: map[string]mask.Direction{
+			http.MethodPost: mask.DirectionRequest,
+			http.MethodGet:  mask.DirectionResponse,
+		},
+	}
+
+	testCases := []struct {
+		testName             string
+		method               string
+		contentType          string
+		routeDirection       mask.Direction
+		requestBody          string
+		expectedStatus       int
+		expectedUpstreamBody string
+		expectedBody         string
+	}{
+		{
+			testName:             "method_masks_request",
+			method:               http.MethodPost,
+			requestBody:          `{"email": "mark@domain.com", "age": 30}`,
+			expectedStatus:       http.StatusOK,
+			expectedUpstreamBody: `{"email":"x","age":30}`,
+			expectedBody:         `{"name": "mark"}`,
+		},
+		{
+			testName:             "method_doesnt_mask",
+			method:               http.MethodPut,
+			requestBody:          `{"email": "mark@domain.com"}`,
+			expectedStatus:       http.StatusOK,
+			expectedUpstreamBody: `{"email": "mark@domain.com"}`,
+			expectedBody:         `{"name": "mark"}`,
+		},
+		{
+			testName:             "route_masks_both",
+			method:               http.MethodPut,
+			routeDirection:       mask.DirectionBoth,
+			requestBody:          `{"email": "mark@domain.com"}`,
+			expectedStatus:       http.StatusOK,
+			expectedUpstreamBody: `{"email":"x"}`,
+			expectedBody:         `{"name":"x"}`,
+		},
+		{
+			testName:             "route_disables_masking",
+			method:               http.MethodPost,
+			routeDirection:       mask.DirectionNone,
+			requestBody:          `{"email": "mark@domain.com"}`,
+			expectedStatus:       http.StatusOK,
+			expectedUpstreamBody: `{"email": "mark@domain.com"}`,
+			expectedBody:         `{"name": "mark"}`,
+		},
+		{
+			testName:             "form_with_charset",
+			method:               http.MethodPost,
+			contentType:          "application/x-www-form-urlencoded; charset=utf-8",
+			requestBody:          `email=mark%40domain.com&page=2`,
+			expectedStatus:       http.StatusOK,
+			expectedUpstreamBody: `email=x&page=2`,
+			expectedBody:         `{"name": "mark"}`,
+		},
+		{
+			testName:             "no_inspector",
+			method:               http.MethodPost,
+			contentType:          "text/plain",
+			requestBody:          `email=mark%40domain.com`,
+			expectedStatus:       http.StatusOK,
+			expectedUpstreamBody: `email=mark%40domain.com`,
+			expectedBody:         `{"name": "mark"}`,
+		},
+		{
+			testName:       "invalid_request_json",
+			method:         http.MethodPost,
+			requestBody:    `{"email": [}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   string(server.ProxyErrorInspectingRequestBody),
+		},
+	}
+
+	for _, test := range testCases {
+		routeDirection := test.routeDirection
+		upstreamBody := ""
+
+		handler := server.Handle(
+			newRegistry(t, map[string]mask.Inspector{
+				"application/json": mask.NewJSONInspector(mask.NewJSONMask(), mask.NewPIIClassifier(mask.NewDefaultPIIPatterns())),
+				"application/x-www-form-urlencoded": mask.NewFormRulesInspector([]mask.Rule{
+					{
+						Classifier: mask.NewPIIClassifier(mask.NewDefaultPIIPatterns()),
+						Mask:       mask.NewJSONMask(),
+					},
+				}),
+			}),
+			&log.ResponseWriterFactoryInstance{
+				Logger: &LoggerMock{},
+			},
+			&GuardMock{
+				func(req *http.Request) bool {
+					return false
+				},
+			},
+			&RouterMock{
+				method: func(req *http.Request) (*route.Match, error) {
+					return &route.Match{
+						Upstream: &proxy.Upstream{
+							Name: "api",
+						},
+						MaskDirection: routeDirection,
+					}, nil
+				},
+			},
+			&ProxyMock{
+				method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+					body, err := io.ReadAll(req.Body)
+					if err != nil {
+						t.Fatalf("failed to read forwarded body %s", err)
+					}
+					upstreamBody = string(body)
+
+					if req.ContentLength != int64(len(body)) {
+						t.Fatalf("%s expected content length %d got %d instead", test.testName, len(body), req.ContentLength)
+					}
+
+					return &http.Response{
+						StatusCode:    http.StatusOK,
+						ContentLength: -1,
+						Body:          io.NopCloser(strings.NewReader(`{"name": "mark"}`)),
+						Header: http.Header{
+							"Content-Type": []string{"application/json"},
+						},
+					}, nil
+				},
+			},
+			server.BufferLimits{},
+			masking,
+		)
+
+		req := httptest.NewRequest(test.method, url, strings.NewReader(test.requestBody))
+		contentType := test.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+
+		req.Header.Set("Content-Type", contentType)
+
+		resp := httptest.NewRecorder()
+		handler(resp, req)
+
+		assert.Equal(t, test.expectedStatus, resp.Code, test.testName+" didnt get expected status code")
+
+		assert.Equal(t, test.expectedUpstreamBody, upstreamBody, test.testName+" didnt forward expected body")
+
+		assert.Equal(t, test.expectedBody, resp.Body.String(), test.testName+" didnt get expected body")
+	}
+}
+
+type RecordingLoggerMock struct {
+	lines []string
+}
+
+func (logger *RecordingLoggerMock) Print(data ...any) {
+	logger.lines = append(logger.lines, fmt.Sprint(data...))
+}
+
+func TestHandleUnknownContent(t *testing.T) {
+	const url = "http://localhost:8000"
+
+	testCases := []struct {
+		testName             string
+		policy               mask.UnknownContentPolicy
+		method               string
+		requestContentType   string
+		responseContentType  string
+		expectedStatus       int
+		expectedBody         string
+		expectedUpstreamBody string
+		expectedLogs         int
+	}{
+		{
+			testName:             "pass",
+			policy:               mask.UnknownContentPass,
+			method:               http.MethodPost,
+			requestContentType:   "text/plain",
+			responseContentType:  "text/plain",
+			expectedStatus:       http.StatusOK,
+			expectedBody:         "name=mark",
+			expectedUpstreamBody: "email=mark",
+		},
+		{
+			testName:             "log",
+			policy:               mask.UnknownContentLog,
+			method:               http.MethodPost,
+			requestContentType:   "text/plain",
+			responseContentType:  "text/plain",
+			expectedStatus:       http.StatusOK,
+			expectedBody:         "name=mark",
+			expectedUpstreamBody: "email=mark",
+			expectedLogs:         2,
+		},
+		{
+			testName:            "block_request",
+			policy:              mask.UnknownContentBlock,
+			method:              http.MethodPost,
+			requestContentType:  "text/plain",
+			responseContentType: "application/json",
+			expectedStatus:      http.StatusUnsupportedMediaType,
+			expectedBody:        string(server.ProxyErrorUnknownRequestContent),
+		},
+		{
+			testName:             "block_response",
+			policy:               mask.UnknownContentBlock,
+			method:               http.MethodPost,
+			requestContentType:   "application/json",
+			responseContentType:  "",
+			expectedStatus:       http.StatusBadGateway,
+			expectedBody:         string(server.ProxyErrorUnknownResponseContent),
+			expectedUpstreamBody: "email=mark",
+		},
+		{
+			testName:             "block_skips_direction_without_masking",
+			policy:               mask.UnknownContentBlock,
+			method:               http.MethodPut,
+			requestContentType:   "text/plain",
+			responseContentType:  "text/plain",
+			expectedStatus:       http.StatusOK,
+			expectedBody:         "name=mark",
+			expectedUpstreamBody: "email=mark",
+		},
+	}
+
+	for _, test := range testCases {
+		responseContentType := test.responseContentType
+		upstreamBody := ""
+		logger := &RecordingLoggerMock{}
+
+		handler := server.Handle(
+			newRegistry(t, map[string]mask.Inspector{
+				"application/json": &InspectorMock{
+					method: func(bytes []byte) ([]byte, error) {
+						return bytes, nil
+					},
+				},
+			}),
+			&log.ResponseWriterFactoryInstance{
+				Logger: &LoggerMock{},
+			},
+			&GuardMock{
+				func(req *http.Request) bool {
+					return false
+				},
+			},
+			&RouterMock{
+				method: func(req *http.Request) (*route.Match, error) {
+					return &route.Match{
+						Upstream: &proxy.Upstream{
+							Name: "api",
+						},
+					}, nil
+				},
+			},
+			&ProxyMock{
+				method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+					body, err := io.ReadAll(req.Body)
+					if err != nil {
+						t.Fatalf("failed to read forwarded body %s", err)
+					}
+					upstreamBody = string(body)
+
+					return &http.Response{
+						StatusCode:    http.StatusOK,
+						Body:          io.NopCloser(strings.NewReader("name=mark")),
+						ContentLength: -1,
+						Header: http.Header{
+							"Content-Type": []string{responseContentType},
+						},
+					}, nil
+				},
+			},
+			server.BufferLimits{},
+			server.MaskingPolicy{
+				Default: mask.DirectionNone,
+				Methods: map[string]mask.Direction{
+					http.MethodPost: mask.DirectionBoth,
+				},
+				Unknown: test.policy,
+				Logger:  logger,
+			},
+		)
+
+		req := httptest.NewRequest(test.method, url, strings.NewReader("email=mark"))
+		req.Header.Set("Content-Type", test.requestContentType)
+
+		resp := httptest.NewRecorder()
+		handler(resp, req)
+
+		assert.Equal(t, test.expectedStatus, resp.Code, test.testName+" didnt get expected status code")
+
+		assert.Equal(t, test.expectedBody, resp.Body.String(), test.testName+" didnt get expected body")
+
+		assert.Equal(t, test.expectedUpstreamBody, upstreamBody, test.testName+" didnt forward expected body")
+
+		assert.Len(t, logger.lines, test.expectedLogs, test.testName+" didnt log expected unmasked bodies")
+	}
+}
+
+func compress(t *testing.T, encoding string, content string) []byte {
+	buff := bytes.NewBuffer(nil)
+
+	var writer io.WriteCloser = gzip.NewWriter(buff)
+
+	switch encoding {
+	case "deflate":
+		writer = zlib.NewWriter(buff)
+	case "br":
+		writer = brotli.NewWriter(buff)
+	}
+
+	_, err := writer.Write([]byte(content))
+	if err != nil {
+		t.Fatalf("failed to compress body %s", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatalf("failed to compress body %s", err)
+	}
+
+	return buff.Bytes()
+}
+
+func decompress(t *testing.T, encoding string, content []byte) string {
+	var reader io.Reader
+
+	var err error
+
+	switch encoding {
+	case "gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(content))
+	case "deflate":
+		reader, err = zlib.NewReader(bytes.NewReader(content))
+	case "br":
+		reader = brotli.NewReader(bytes.NewReader(content))
+	default:
+		reader = bytes.NewReader(content)
+	}
+
+	if err != nil {
+		t.Fatalf("failed to decompress body %s", err)
+	}
+
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to decompress body %s", err)
+	}
+
+	return string(decompressed)
+}
+
+func TestHandleCompressedResponse(t *testing.T) {
+	const url = "http://localhost:8000"
+
+	const upstreamBody = `{"name": "mark", "id": 1}`
+	const maskedBody = `{"name":"x","id":1}`
+
+	testCases := []struct {
+		testName                 string
+		acceptEncoding           string
+		upstreamEncoding         string
+		streams                  bool
+		expectedStatus           int
+		expectedEncoding         string
+		expectedBody             string
+		expectedUpstreamEncoding string
+	}{
+		{
+			testName:                 "gzip",
+			acceptEncoding:           "gzip, deflate, br",
+			upstreamEncoding:         "gzip",
+			expectedStatus:           http.StatusOK,
+			expectedEncoding:         "gzip",
+			expectedBody:             maskedBody,
+			expectedUpstreamEncoding: "gzip;q=1, br;q=1, deflate;q=1, identity;q=1",
+		},
+		{
+			testName:                 "br",
+			acceptEncoding:           "br",
+			upstreamEncoding:         "br",
+			expectedStatus:           http.StatusOK,
+			expectedEncoding:         "br",
+			expectedBody:             maskedBody,
+			expectedUpstreamEncoding: "br;q=1, identity;q=1",
+		},
+		{
+			testName:                 "br_streamed",
+			acceptEncoding:           "gzip, br",
+			upstreamEncoding:         "br",
+			streams:                  true,
+			expectedStatus:           http.StatusOK,
+			expectedEncoding:         "br",
+			expectedBody:             maskedBody,
+			expectedUpstreamEncoding: "gzip;q=1, br;q=1, identity;q=1",
+		},
+		{
+			testName:                 "br_to_accepted_deflate",
+			acceptEncoding:           "deflate",
+			upstreamEncoding:         "br",
+			expectedStatus:           http.StatusOK,
+			expectedEncoding:         "deflate",
+			expectedBody:             maskedBody,
+			expectedUpstreamEncoding: "deflate;q=1, identity;q=1",
+		},
+		{
+			testName:                 "gzip_streamed",
+			acceptEncoding:           "gzip",
+			upstreamEncoding:         "gzip",
+			streams:                  true,
+			expectedStatus:           http.StatusOK,
+			expectedEncoding:         "gzip",
+			expectedBody:             maskedBody,
+			expectedUpstreamEncoding: "gzip;q=1, identity;q=1",
+		},
+		{
+			testName:                 "deflate_to_accepted_gzip",
+			acceptEncoding:           "gzip;q=0.5, zstd",
+			upstreamEncoding:         "deflate",
+			expectedStatus:           http.StatusOK,
+			expectedEncoding:         "gzip",
+			expectedBody:             maskedBody,
+			expectedUpstreamEncoding: "gzip;q=0.5, identity;q=1",
+		},
+		{
+			testName:                 "gzip_to_identity",
+			acceptEncoding:           "zstd, *;q=0",
+			upstreamEncoding:         "gzip",
+			expectedStatus:           http.StatusOK,
+			expectedEncoding:         "",
+			expectedBody:             maskedBody,
+			expectedUpstreamEncoding: "identity",
+		},
+		{
+			testName:                 "unsupported",
+			acceptEncoding:           "zstd",
+			upstreamEncoding:         "zstd",
+			expectedStatus:           http.StatusBadGateway,
+			expectedEncoding:         "",
+			expectedBody:             string(server.ProxyErrorUnsupportedEncoding),
+			expectedUpstreamEncoding: "identity;q=1",
+		},
+	}
+
+	for _, test := range testCases {
+		upstreamEncoding := test.upstreamEncoding
+		forwardedEncoding := ""
+
+		var inspector mask.Inspector = mask.NewJSONInspector(mask.NewJSONMask(), mask.NewPIIClassifier(mask.NewDefaultPIIPatterns()))
+		if !test.streams {
+			inspector = &InspectorMock{
+				method: func(bytes []byte) ([]byte, error) {
+					return mask.NewJSONInspector(mask.NewJSONMask(), mask.NewPIIClassifier(mask.NewDefaultPIIPatterns())).Inspect(bytes)
+				},
+			}
+		}
+
+		handler := server.Handle(
+			newRegistry(t, map[string]mask.Inspector{
+				"application/json": inspector,
+			}),
+			&log.ResponseWriterFactoryInstance{
+				Logger: &LoggerMock{},
+			},
+			&GuardMock{
+				func(req *http.Request) bool {
+					return false
+				},
+			},
+			&RouterMock{
+				method: func(req *http.Request) (*route.Match, error) {
+					return &route.Match{
+						Upstream: &proxy.Upstream{
+							Name: "api",
+						},
+					}, nil
+				},
+			},
+			&ProxyMock{
+				method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+					forwardedEncoding = req.Header.Get("Accept-Encoding")
+
+					body := []byte(upstreamBody)
+					if upstreamEncoding != "zstd" {
+						body = compress(t, upstreamEncoding, upstreamBody)
+					}
+
+					return &http.Response{
+						StatusCode:    http.StatusOK,
+						ContentLength: int64(len(body)),
+						Body:          io.NopCloser(bytes.NewReader(body)),
+						Header: http.Header{
+							"Content-Type":     []string{"application/json"},
+							"Content-Encoding": []string{upstreamEncoding},
+							"Content-Length":   []string{strconv.Itoa(len(body))},
+						},
+					}, nil
+				},
+			},
+			server.BufferLimits{},
+			server.DefaultMaskingPolicy(),
+		)
+
+		req := httptest.NewRequest(http.MethodGet, url, http.NoBody)
+		req.Header.Set("Accept-Encoding", test.acceptEncoding)
+
+		resp := httptest.NewRecorder()
+		handler(resp, req)
+
+		assert.Equal(t, test.expectedStatus, resp.Code, test.testName+" didnt get expected status code")
+
+		assert.Equal(t, test.expectedUpstreamEncoding, forwardedEncoding, test.testName+" didnt forward expected accept encoding")
+
+		assert.Equal(t, test.expectedEncoding, resp.Header().Get("Content-Encoding"), test.testName+" didnt get expected content encoding")
+
+		assert.Equal(t, test.expectedBody, decompress(t, test.expectedEncoding, resp.Body.Bytes()), test.testName+" didnt get expected body")
+
+		contentLength := resp.Header().Get("Content-Length")
+		if contentLength != "" && contentLength != strconv.Itoa(resp.Body.Len()) {
+			t.Fatalf("%s expected content length %d got %s instead", test.testName, resp.Body.Len(), contentLength)
+		}
+	}
+}
+
+func TestHandleRateLimited(t *testing.T) {
+	guard, err := block.GuardsFromInterface(map[string]interface{}{
+		"rate_limit": "ip",
+		"rate":       1,
+		"per":        "1h",
+	}, &block.InterfaceGuardDecoder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxyMock := &ProxyMock{
+		method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader("ok")),
+				Header:     http.Header{},
+			}, nil
+		},
+	}
+
+	router := &RouterMock{
+		method: func(req *http.Request) (*route.Match, error) {
+			return &route.Match{Upstream: &proxy.Upstream{Name: "api", Scheme: "https"}}, nil
+		},
+	}
+
+	handler := server.Handle(newRegistry(t, nil), &log.ResponseWriterFactoryInstance{Logger: &LoggerMock{}}, guard, router, proxyMock, server.BufferLimits{
+		MaxRequestBody:  10,
+		MaxResponseBody: 10,
+	}, server.DefaultMaskingPolicy())
+
+	first := httptest.NewRecorder()
+	handler(first, httptest.NewRequest(http.MethodGet, "http://localhost:8000", nil))
+
+	assert.Equal(t, http.StatusOK, first.Result().StatusCode, "first request should pass rate limit")
+
+	second := httptest.NewRecorder()
+	handler(second, httptest.NewRequest(http.MethodGet, "http://localhost:8000", nil))
+
+	assert.Equal(t, http.StatusTooManyRequests, second.Result().StatusCode, "second request should be rate limited")
+	assert.Equal(t, "3600", second.Header().Get("Retry-After"), "rate limited request should tell when to retry")
+	assert.Equal(t, server.ProxyResponseHeaderError, second.Header().Get(server.ProxyResponseHeader))
+	assert.Equal(t, string(server.ProxyErrorRateLimited), second.Body.String())
+}
+
+func TestHandleBodyGuard(t *testing.T) {
+	guard, err := block.GuardsFromInterface(map[string]interface{}{
+		"body_field": "$.role",
+		"value":      "admin",
+	}, &block.InterfaceGuardDecoder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var forwardedBody []byte
+
+	proxyMock := &ProxyMock{
+		method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+			forwardedBody, err = io.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader("ok")),
+				Header:     http.Header{},
+			}, nil
+		},
+	}
+
+	router := &RouterMock{
+		method: func(req *http.Request) (*route.Match, error) {
+			return &route.Match{Upstream: &proxy.Upstream{Name: "api", Scheme: "https"}}, nil
+		},
+	}
+
+	handler := server.Handle(newRegistry(t, nil), &log.ResponseWriterFactoryInstance{Logger: &LoggerMock{}}, guard, router, proxyMock, server.BufferLimits{
+		MaxRequestBody:  100,
+		MaxResponseBody: 100,
+	}, server.DefaultMaskingPolicy())
+
+	blocked := httptest.NewRecorder()
+	handler(blocked, httptest.NewRequest(http.MethodPost, "http://localhost:8000", strings.NewReader(`{"role": "admin"}`)))
+
+	assert.Equal(t, http.StatusForbidden, blocked.Result().StatusCode, "request with blocked body field should be blocked")
+	assert.Equal(t, string(server.ProxyErrorBlock), blocked.Body.String())
+
+	passed := httptest.NewRecorder()
+	handler(passed, httptest.NewRequest(http.MethodPost, "http://localhost:8000", strings.NewReader(`{"role": "user"}`)))
+
+	assert.Equal(t, http.StatusOK, passed.Result().StatusCode, "request with allowed body field should pass")
+	assert.Equal(t, `{"role": "user"}`, string(forwardedBody), "checked body should be forwarded whole")
+}
+
+func TestHandleBuffersRetriedRequestBody(t *testing.T) {
+	retryPolicy := &proxy.RetryPolicy{MaxAttempts: 3}
+
+	testCases := []struct {
+		testName       string
+		method         string
+		retryPolicy    *proxy.RetryPolicy
+		expectedReplay bool
+	}{
+		{
+			testName:       "retried_put",
+			method:         http.MethodPut,
+			retryPolicy:    retryPolicy,
+			expectedReplay: true,
+		},
+		{
+			testName:       "not_retried_post",
+			method:         http.MethodPost,
+			retryPolicy:    retryPolicy,
+			expectedReplay: false,
+		},
+		{
+			testName:       "retried_post",
+			method:         http.MethodPost,
+			retryPolicy:    &proxy.RetryPolicy{MaxAttempts: 3, RetryNonIdempotent: true},
+			expectedReplay: true,
+		},
+		{
+			testName:       "upstream_without_retries",
+			method:         http.MethodPut,
+			retryPolicy:    nil,
+			expectedReplay: false,
+		},
+	}
+
+	for _, test := range testCases {
+		canReplay := false
+
+		handler := server.Handle(
+			newRegistry(t, nil),
+			&log.ResponseWriterFactoryInstance{Logger: &LoggerMock{}},
+			&GuardMock{
+				func(req *http.Request) bool {
+					return false
+				},
+			},
+			&RouterMock{
+				method: func(req *http.Request) (*route.Match, error) {
+					return &route.Match{Upstream: &proxy.Upstream{Name: "api", RetryPolicy: test.retryPolicy}}, nil
+				},
+			},
+			&ProxyMock{
+				method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+					canReplay = req.GetBody != nil
+
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       io.NopCloser(strings.NewReader("ok")),
+						Header:     http.Header{},
+					}, nil
+				},
+			},
+			server.BufferLimits{},
+			server.DefaultMaskingPolicy(),
+		)
+
+		resp := httptest.NewRecorder()
+		handler(resp, httptest.NewRequest(test.method, "http://localhost:8000", strings.NewReader(`{"id": 1}`)))
+
+		assert.Equal(t, http.StatusOK, resp.Code, test.testName+" didnt get expected status code")
+		assert.Equal(t, test.expectedReplay, canReplay, test.testName+" didnt buffer body as expected")
+	}
+}
+
+func TestHandleBodylessResponse(t *testing.T) {
+	testCases := []struct {
+		testName       string
+		method         string
+		status         int
+		headers        http.Header
+		contentLength  int64
+		expectedStatus int
+	}{
+		{
+			testName:       "no_content",
+			method:         http.MethodGet,
+			status:         http.StatusNoContent,
+			headers:        http.Header{"Content-Type": []string{"application/json"}},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			testName:       "not_modified",
+			method:         http.MethodGet,
+			status:         http.StatusNotModified,
+			headers:        http.Header{"Content-Type": []string{"application/json"}},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			testName:       "not_modified_gzip",
+			method:         http.MethodGet,
+			status:         http.StatusNotModified,
+			headers:        http.Header{"Content-Type": []string{"application/json"}, "Content-Encoding": []string{"gzip"}},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			testName:       "head_gzip",
+			method:         http.MethodHead,
+			status:         http.StatusOK,
+			headers:        http.Header{"Content-Type": []string{"application/json"}, "Content-Encoding": []string{"gzip"}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "empty_chunked_gzip",
+			method:         http.MethodGet,
+			status:         http.StatusOK,
+			headers:        http.Header{"Content-Type": []string{"application/json"}, "Content-Encoding": []string{"gzip"}},
+			contentLength:  -1,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, test := range testCases {
+		handler := server.Handle(
+			newRegistry(t, map[string]mask.Inspector{
+				"application/json": &InspectorMock{
+					method: func(bytes []byte) ([]byte, error) {
+						return nil, errors.New("bodyless response shouldnt be inspected")
+					},
+				},
+			}),
+			&log.ResponseWriterFactoryInstance{Logger: &LoggerMock{}},
+			&GuardMock{
+				func(req *http.Request) bool {
+					return false
+				},
+			},
+			&RouterMock{
+				method: func(req *http.Request) (*route.Match, error) {
+					return &route.Match{Upstream: &proxy.Upstream{Name: "api"}}, nil
+				},
+			},
+			&ProxyMock{
+				method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+					return &http.Response{
+						StatusCode:    test.status,
+						Body:          http.NoBody,
+						Header:        test.headers,
+						ContentLength: test.contentLength,
+					}, nil
+				},
+			},
+			server.BufferLimits{},
+			server.DefaultMaskingPolicy(),
+		)
+
+		resp := httptest.NewRecorder()
+		handler(resp, httptest.NewRequest(test.method, "http://localhost:8000", http.NoBody))
+
+		assert.Equal(t, test.expectedStatus, resp.Code, test.testName+" didnt get expected status code")
+		assert.Equal(t, server.ProxyResponseHeaderSuccess, resp.Header().Get(server.ProxyResponseHeader), test.testName+" didnt get expected proxy error header")
+		assert.Empty(t, resp.Body.String(), test.testName+" didnt get expected body")
+		assert.Equal(t, test.headers.Get("Content-Encoding"), resp.Header().Get("Content-Encoding"), test.testName+" didnt keep content encoding")
+	}
+}
+
+func TestHandleLogsMaskedResponse(t *testing.T) {
+	testCases := []struct {
+		testName    string
+		contentType string
+		body        string
+		inspector   mask.Inspector
+		expectedLog string
+	}{
+		{
+			testName:    "streamed_json",
+			contentType: "application/json",
+			body:        `{"name": "mark", "id": 1}`,
+			inspector:   mask.NewJSONInspector(mask.NewJSONMask(), mask.NewPIIClassifier(mask.NewDefaultPIIPatterns())),
+			expectedLog: `{\"name\":\"x\",\"id\":1}`,
+		},
+		{
+			testName:    "buffered_xml",
+			contentType: "application/xml",
+			body:        `<user><name>mark</name></user>`,
+			inspector:   mask.NewXMLRulesInspector([]mask.Rule{{Classifier: mask.NewPIIClassifier(mask.NewDefaultPIIPatterns()), Mask: mask.NewJSONMask()}}),
+			expectedLog: `\u003cname\u003ex\u003c/name\u003e`,
+		},
+	}
+
+	for _, test := range testCases {
+		logger := &RecordingLoggerMock{}
+
+		handler := server.Handle(
+			newRegistry(t, map[string]mask.Inspector{test.contentType: test.inspector}),
+			&log.ResponseWriterFactoryInstance{Logger: logger},
+			&GuardMock{
+				func(req *http.Request) bool {
+					return false
+				},
+			},
+			&RouterMock{
+				method: func(req *http.Request) (*route.Match, error) {
+					return &route.Match{Upstream: &proxy.Upstream{Name: "api"}}, nil
+				},
+			},
+			&ProxyMock{
+				method: func(req *http.Request, upstream *proxy.Upstream) (*http.Response, error) {
+					return &http.Response{
+						StatusCode:    http.StatusOK,
+						Body:          io.NopCloser(strings.NewReader(test.body)),
+						ContentLength: -1,
+						Header:        http.Header{"Content-Type": []string{test.contentType}},
+					}, nil
+				},
+			},
+			server.BufferLimits{},
+			server.DefaultMaskingPolicy(),
+		)
+
+		resp := httptest.NewRecorder()
+		handler(resp, httptest.NewRequest(http.MethodGet, "http://localhost:8000", http.NoBody))
+
+		assert.Equal(t, http.StatusOK, resp.Code, test.testName+" didnt get expected status code")
+		assert.Len(t, logger.lines, 1, test.testName+" didnt log response")
+
+		for _, line := range logger.lines {
+			assert.Contains(t, line, test.expectedLog, test.testName+" didnt log masked body")
+		}
+	}
+}